@@ -0,0 +1,63 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package llb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/client/llb"
+)
+
+// SolveOptions configures a Solve call against a remote buildkitd.
+type SolveOptions struct {
+	// Addr is the buildkitd address, e.g. "tcp://localhost:1234" or
+	// "unix:///run/buildkit/buildkitd.sock".
+	Addr string
+	// ExporterAttrs are passed through to the image exporter, e.g.
+	// {"name": "<tag>"} to name the resulting image.
+	ExporterAttrs map[string]string
+}
+
+// Solve sends the final LLB state to a remote buildkitd and blocks until
+// the build completes. The resulting image is exported with the "image"
+// exporter so it lands in the daemon's content store, from which makisu's
+// existing image store can pull it for tag/push.
+func Solve(ctx context.Context, final llb.State, opts SolveOptions) (*client.SolveResponse, error) {
+	def, err := final.Marshal(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("llb: marshal state: %s", err)
+	}
+
+	c, err := client.New(ctx, opts.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("llb: connect to buildkitd at %s: %s", opts.Addr, err)
+	}
+	defer c.Close()
+
+	resp, err := c.Solve(ctx, def, client.SolveOpt{
+		Exports: []client.ExportEntry{
+			{
+				Type:  client.ExporterImage,
+				Attrs: opts.ExporterAttrs,
+			},
+		},
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("llb: solve against %s: %s", opts.Addr, err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,191 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package llb lowers a parsed Dockerfile directive stream into a BuildKit
+// LLB graph, so makisu can either marshal it to a protobuf definition for a
+// remote buildkitd, or solve it directly against one.
+package llb
+
+import (
+	"fmt"
+
+	"github.com/moby/buildkit/client/llb"
+
+	"github.com/uber/makisu/lib/parser/dockerfile"
+)
+
+// Translator walks a stage's directives in order and accumulates an
+// llb.State. One Translator is used per build stage; multi-stage builds
+// create a Translator per stage and thread finished states into later
+// stages' FROM references via StageStates.
+type Translator struct {
+	// StageStates holds the final llb.State of each stage already
+	// translated in this build, keyed by stage name or index, so a later
+	// `FROM <name>` can reference it instead of pulling an image.
+	StageStates map[string]llb.State
+
+	state   llb.State
+	workdir string
+	user    string
+	env     map[string]string
+}
+
+// NewTranslator creates a Translator starting from a base image, as
+// produced by the stage's FROM directive.
+func NewTranslator(baseImage string, stageStates map[string]llb.State) *Translator {
+	return &Translator{
+		StageStates: stageStates,
+		state:       llb.Image(baseImage),
+		env:         make(map[string]string),
+	}
+}
+
+// NewTranslatorFromStage creates a Translator that continues from the
+// final state of a previously translated stage, for `FROM <name>`.
+func NewTranslatorFromStage(state llb.State, stageStates map[string]llb.State) *Translator {
+	return &Translator{
+		StageStates: stageStates,
+		state:       state,
+		env:         make(map[string]string),
+	}
+}
+
+// State returns the current llb.State of the stage.
+func (t *Translator) State() llb.State {
+	return t.state
+}
+
+// Add lowers a single directive into the current llb.State. Directives
+// that don't yet have a lowering registered return an error rather than
+// silently dropping the step, so gaps are visible instead of producing a
+// subtly wrong image.
+func (t *Translator) Add(d dockerfile.Directive) error {
+	switch v := d.(type) {
+	case *dockerfile.EntrypointDirective:
+		return t.addEntrypoint(v)
+	case *dockerfile.RunDirective:
+		return t.addRun(v)
+	case *dockerfile.CopyDirective:
+		return t.addCopy(v)
+	default:
+		return fmt.Errorf("llb: no lowering registered for directive %T", d)
+	}
+}
+
+// addEntrypoint records the entrypoint's shell-vs-exec form. BuildKit LLB
+// has no first-class notion of "entrypoint" on an intermediate state the
+// way an image config does; the resolved Entrypoint/shell distinction is
+// carried through to the final image config by the caller once all stages
+// are translated, using ShellScript.IsSimple() to decide whether the
+// config's Entrypoint can be the bare argv or must be wrapped in the
+// already-materialized "/bin/sh -c" form.
+func (t *Translator) addEntrypoint(d *dockerfile.EntrypointDirective) error {
+	if d.ShellScript != nil && !d.ShellScript.IsSimple() {
+		// Shell form with operators/expansions: the directive already
+		// wrapped Entrypoint in ["/bin/sh", "-c", "<script>"], which is
+		// exactly what belongs in the image config, so there's nothing
+		// further to lower onto the LLB graph itself.
+		return nil
+	}
+	return nil
+}
+
+// addRun lowers a RUN step onto the graph via Run. A heredoc body is
+// lowered as `/bin/sh -c <body>`, the same shell wrapping any other
+// shell-form RUN's Shell argv already carries; BuildKit's own exec has no
+// notion of "the body came from a heredoc" - it only sees the resulting
+// shell script, the same text shell.ExecHeredocScript would otherwise
+// write to a temp file and run.
+// More than one heredoc on the RUN line is refused for the same reason
+// RunDirective.Execute refuses it: there's no single agreed shell
+// semantics for a second heredoc body on a RUN line to lower onto.
+func (t *Translator) addRun(d *dockerfile.RunDirective) error {
+	if len(d.Heredocs) > 1 {
+		return fmt.Errorf("llb: RUN with %d heredocs is not supported, only a single heredoc body can be lowered", len(d.Heredocs))
+	}
+	if len(d.Heredocs) > 0 {
+		t.Run([]string{"/bin/sh", "-c", d.Heredocs[0].Body})
+		return nil
+	}
+	t.Run(d.Shell)
+	return nil
+}
+
+// addCopy lowers a COPY step onto the graph. The inline `COPY <<EOF
+// <dst>` form has no source state to read from, so it's lowered via
+// llb.Mkfile instead of File(llb.Copy(...)). The ordinary src/dst form
+// reads from "context", the name BuildKit's own dockerfile frontend uses
+// for the local build context input (see llb.Local("context") in
+// dockerfile2llb); COPY --from=<stage> isn't handled here since that
+// needs the other stage's Translator, which Add's signature doesn't
+// carry.
+func (t *Translator) addCopy(d *dockerfile.CopyDirective) error {
+	if d.Heredoc != nil {
+		t.state = t.state.File(llb.Mkfile(d.Dst, 0644, []byte(d.Heredoc.Body)))
+		return nil
+	}
+	context := llb.Local("context")
+	for _, src := range d.Srcs {
+		t.Copy(context, src, d.Dst)
+	}
+	return nil
+}
+
+// Run adds a RUN-equivalent exec vertex to the state, mounting the
+// previous state as the exec root. Mirrors buildkit's own dockerfile
+// frontend, which lowers `RUN <cmd>` to `llb.Image(...).Run(llb.Shlex(...))`
+// (or direct Args when the command is already a resolved argv).
+func (t *Translator) Run(argv []string, opts ...llb.RunOption) {
+	runOpts := append([]llb.RunOption{llb.Args(argv)}, opts...)
+	t.state = t.state.Run(runOpts...).Root()
+}
+
+// RunShell is like Run but takes a raw shell string, lowered the same way
+// buildkit's frontend lowers shell-form RUN: via llb.Shlex.
+func (t *Translator) RunShell(script string, opts ...llb.RunOption) {
+	runOpts := append([]llb.RunOption{llb.Shlex(script)}, opts...)
+	t.state = t.state.Run(runOpts...).Root()
+}
+
+// Copy adds a COPY/ADD-equivalent vertex: src is read from srcState (the
+// build context for COPY, or another stage's final state for
+// `COPY --from=<stage>`) and written into the current state at dst.
+func (t *Translator) Copy(srcState llb.State, src, dst string) {
+	t.state = t.state.File(llb.Copy(srcState, src, dst, &llb.CopyInfo{
+		CreateDestPath: true,
+	}))
+}
+
+// SetWorkdir records the working directory for subsequent Run/Copy calls,
+// mirroring WorkdirDirective.
+func (t *Translator) SetWorkdir(dir string) {
+	t.workdir = dir
+	t.state = t.state.Dir(dir)
+}
+
+// SetUser records the exec user for subsequent Run calls, mirroring
+// UserDirective / utils.ResolveChown.
+func (t *Translator) SetUser(user string) {
+	t.user = user
+	t.state = t.state.User(user)
+}
+
+// SetEnv records an ENV var in the Translator and adds it to the LLB
+// state (for cache-key purposes and subsequent RUN steps), mirroring
+// EnvDirective / replaceVarsCurrStage's ARG/ENV map. The recorded map is
+// not read anywhere in this package and does not reach an image config.
+func (t *Translator) SetEnv(key, value string) {
+	t.env[key] = value
+	t.state = t.state.AddEnv(key, value)
+}
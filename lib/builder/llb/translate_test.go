@@ -0,0 +1,98 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package llb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uber/makisu/lib/parser/dockerfile"
+	"github.com/uber/makisu/lib/parser/dockerfile/shellparse"
+)
+
+func TestTranslatorAddRun(t *testing.T) {
+	tr := NewTranslator("alpine", nil)
+
+	run := &dockerfile.RunDirective{Shell: []string{"/bin/sh", "-c", "echo hi"}}
+	if err := tr.Add(run); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tr.State().Marshal(context.Background()); err != nil {
+		t.Fatalf("marshal state after RUN: %s", err)
+	}
+}
+
+func TestTranslatorAddRunHeredoc(t *testing.T) {
+	tr := NewTranslator("alpine", nil)
+
+	run := &dockerfile.RunDirective{
+		Heredocs: []*shellparse.Heredoc{{Delimiter: "EOF", Body: "echo from heredoc"}},
+	}
+	if err := tr.Add(run); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tr.State().Marshal(context.Background()); err != nil {
+		t.Fatalf("marshal state after heredoc RUN: %s", err)
+	}
+}
+
+func TestTranslatorAddRunRefusesMultipleHeredocs(t *testing.T) {
+	tr := NewTranslator("alpine", nil)
+
+	run := &dockerfile.RunDirective{
+		Heredocs: []*shellparse.Heredoc{
+			{Delimiter: "A", Body: "body of A"},
+			{Delimiter: "B", Body: "body of B"},
+		},
+	}
+	if err := tr.Add(run); err == nil {
+		t.Fatal("expected an error lowering a RUN with more than one heredoc")
+	}
+}
+
+func TestTranslatorAddCopyHeredoc(t *testing.T) {
+	tr := NewTranslator("alpine", nil)
+
+	cp := &dockerfile.CopyDirective{
+		Dst:     "greeting.txt",
+		Heredoc: &shellparse.Heredoc{Delimiter: "EOF", Body: "hello"},
+	}
+	if err := tr.Add(cp); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tr.State().Marshal(context.Background()); err != nil {
+		t.Fatalf("marshal state after COPY heredoc: %s", err)
+	}
+}
+
+func TestTranslatorAddCopyOrdinary(t *testing.T) {
+	tr := NewTranslator("alpine", nil)
+
+	cp := &dockerfile.CopyDirective{Srcs: []string{"a.txt", "b.txt"}, Dst: "/dst/"}
+	if err := tr.Add(cp); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tr.State().Marshal(context.Background()); err != nil {
+		t.Fatalf("marshal state after COPY: %s", err)
+	}
+}
+
+func TestTranslatorAddUnregisteredDirective(t *testing.T) {
+	tr := NewTranslator("alpine", nil)
+	if err := tr.Add(nil); err == nil {
+		t.Fatal("expected an error for a nil/unregistered directive")
+	}
+}
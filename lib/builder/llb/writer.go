@@ -0,0 +1,38 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package llb
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/moby/buildkit/client/llb"
+)
+
+// Marshal lowers the final state of a build into an LLB definition and
+// writes its protobuf encoding to w, for handoff to a remote buildkitd
+// (e.g. `buildctl build --local context=. ... < llb.pb`). The makisu CLI
+// has no flag that calls it; callers invoke it directly.
+func Marshal(ctx context.Context, final llb.State, w io.Writer) error {
+	def, err := final.Marshal(ctx)
+	if err != nil {
+		return fmt.Errorf("llb: marshal state: %s", err)
+	}
+	if err := llb.WriteTo(def, w); err != nil {
+		return fmt.Errorf("llb: write definition: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dockerfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/uber/makisu/lib/parser/dockerfile/shellparse"
+)
+
+func TestCopyDirectiveExecuteHeredoc(t *testing.T) {
+	d := &CopyDirective{
+		Dst:     "nested/dir/greeting.txt",
+		Heredoc: &shellparse.Heredoc{Delimiter: "EOF", Body: "hello from heredoc"},
+	}
+
+	root := t.TempDir()
+	if err := d.Execute(root); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(root, d.Dst))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello from heredoc" {
+		t.Fatalf("content = %q, want %q", got, "hello from heredoc")
+	}
+}
+
+func TestCopyDirectiveExecuteNonHeredocIsNoOp(t *testing.T) {
+	d := &CopyDirective{Srcs: []string{"a.txt"}, Dst: "b.txt"}
+	if err := d.Execute(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -14,12 +14,20 @@
 
 package dockerfile
 
-import "strings"
+import (
+	"github.com/uber/makisu/lib/parser/dockerfile/shellparse"
+)
 
 // EntrypointDirective represents the "ENTRYPOINT" dockerfile command.
 type EntrypointDirective struct {
 	*baseDirective
 	Entrypoint []string
+
+	// ShellScript is the parsed shell-form AST, set only when this
+	// directive was written in shell form. It lets callers decide whether
+	// Entrypoint can be exec'd directly (ShellScript.IsSimple()) instead
+	// of running under /bin/sh.
+	ShellScript *shellparse.Script
 }
 
 // Variables:
@@ -33,18 +41,40 @@ func newEntrypointDirective(base *baseDirective, state *parsingState) (Directive
 	}
 
 	if entrypoint, ok := parseJSONArray(base.Args); ok {
-		return &EntrypointDirective{base, entrypoint}, nil
+		return &EntrypointDirective{baseDirective: base, Entrypoint: entrypoint}, nil
+	}
+
+	// If the line reader buffered one or more heredoc bodies onto
+	// base.Args (e.g. `ENTRYPOINT <<EOF`), the command line is the part
+	// before the first newline and the first heredoc's body is the
+	// script to run; unlike RUN, there is no container rootfs yet to
+	// write a temp file into, so the body is passed to `sh -c` directly.
+	cmdLine, heredocs, err := shellparse.SplitHeredocs(base.Args)
+	if err != nil {
+		return nil, base.err(err)
+	}
+	if len(heredocs) > 0 {
+		cmd := []string{"/bin/sh", "-c", heredocs[0].Body}
+		return &EntrypointDirective{baseDirective: base, Entrypoint: cmd}, nil
 	}
 
 	// This is the Shell form (https://docs.docker.com/engine/reference/builder/#shell-form-entrypoint-example)
-	// It is expected to wrap the whole entrypoint into a sh -c command)
-	args, err := splitArgs(base.Args, true)
+	// Parse it into a shellparse.Script so a simple single command can be
+	// exec'd directly; anything with operators, pipelines, subshells, or
+	// expansions still gets wrapped into a sh -c command.
+	script, err := shellparse.Parse(cmdLine)
 	if err != nil {
 		return nil, base.err(err)
 	}
 
-	cmd := append([]string{"/bin/sh", "-c"}, strings.Join(args, " "))
-	return &EntrypointDirective{base, cmd}, nil
+	cmd := []string{"/bin/sh", "-c", shellparse.Reconstruct(script)}
+	if script.IsSimple() {
+		// A single command with no pipeline/operator/subshell/redirect/
+		// expansion can be exec'd directly, skipping the extra /bin/sh
+		// process the shell form otherwise always forks.
+		cmd = script.Argv()
+	}
+	return &EntrypointDirective{baseDirective: base, Entrypoint: cmd, ShellScript: script}, nil
 }
 
 // Add this command to the build stage.
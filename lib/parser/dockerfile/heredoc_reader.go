@@ -0,0 +1,79 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dockerfile
+
+import (
+	"fmt"
+
+	"github.com/uber/makisu/lib/parser/dockerfile/shellparse"
+)
+
+// CollectHeredocLines is the integration point the Dockerfile line
+// reader must call before a directive's Args reaches splitArgs or
+// shellparse.Parse: given the directive's first line, it keeps pulling
+// further raw lines from next until shellparse.SplitHeredocs can
+// successfully split every `<<DELIM` opened on the first line, then
+// returns the combined, newline-joined text ready to hand to
+// SplitHeredocs. A first line with no real `<<DELIM` operator is
+// returned unchanged without calling next - this is decided by actually
+// lexing the line rather than a bare substring check, since an ordinary
+// line can contain a literal "<<" inside a quoted word (e.g.
+// `RUN echo "the operator is <<"`) without opening a heredoc at all.
+//
+// The loop that walks a Dockerfile's raw lines and decides where one
+// directive ends and the next begins lives outside this package; this
+// function is where that loop must plug in so a heredoc body actually
+// reaches SplitHeredocs as multi-line text instead of the single
+// buffered line it would otherwise see.
+func CollectHeredocLines(firstLine string, next func() (line string, ok bool)) (string, error) {
+	want, err := countHeredocOps(firstLine)
+	if err != nil {
+		return "", err
+	}
+	if want == 0 {
+		return firstLine, nil
+	}
+
+	combined := firstLine
+	for {
+		line, ok := next()
+		if !ok {
+			return "", fmt.Errorf("dockerfile: unterminated heredoc in %q", firstLine)
+		}
+		combined += "\n" + line
+
+		if _, heredocs, err := shellparse.SplitHeredocs(combined); err == nil && len(heredocs) == want {
+			return combined, nil
+		}
+	}
+}
+
+// countHeredocOps lexes line and counts its real TokenHeredocOp tokens,
+// the same count SplitHeredocs itself derives from the command line, so
+// a literal "<<" inside a quoted word is never mistaken for an opened
+// heredoc.
+func countHeredocOps(line string) (int, error) {
+	tokens, err := shellparse.Lex(line)
+	if err != nil {
+		return 0, fmt.Errorf("dockerfile: lex %q: %s", line, err)
+	}
+	var count int
+	for _, tok := range tokens {
+		if tok.Kind == shellparse.TokenHeredocOp {
+			count++
+		}
+	}
+	return count, nil
+}
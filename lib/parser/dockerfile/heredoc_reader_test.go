@@ -0,0 +1,103 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dockerfile
+
+import "testing"
+
+func TestCollectHeredocLinesNoHeredocIsNoOp(t *testing.T) {
+	calls := 0
+	next := func() (string, bool) {
+		calls++
+		return "", false
+	}
+	got, err := CollectHeredocLines("RUN echo hi", next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "RUN echo hi" {
+		t.Fatalf("got %q", got)
+	}
+	if calls != 0 {
+		t.Fatalf("next called %d times, want 0", calls)
+	}
+}
+
+func TestCollectHeredocLinesSingleHeredoc(t *testing.T) {
+	lines := []string{"echo one", "echo two", "EOF"}
+	i := 0
+	next := func() (string, bool) {
+		if i >= len(lines) {
+			return "", false
+		}
+		line := lines[i]
+		i++
+		return line, true
+	}
+	got, err := CollectHeredocLines("RUN <<EOF", next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "RUN <<EOF\necho one\necho two\nEOF"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestCollectHeredocLinesMultipleHeredocsOneLine(t *testing.T) {
+	lines := []string{"body of A", "A", "body of B", "B"}
+	i := 0
+	next := func() (string, bool) {
+		if i >= len(lines) {
+			return "", false
+		}
+		line := lines[i]
+		i++
+		return line, true
+	}
+	got, err := CollectHeredocLines("RUN <<A <<B", next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "RUN <<A <<B\nbody of A\nA\nbody of B\nB"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestCollectHeredocLinesUnterminated(t *testing.T) {
+	next := func() (string, bool) { return "", false }
+	if _, err := CollectHeredocLines("RUN <<EOF", next); err == nil {
+		t.Fatal("expected error for unterminated heredoc")
+	}
+}
+
+func TestCollectHeredocLinesLiteralAngleBracketsIsNoOp(t *testing.T) {
+	calls := 0
+	next := func() (string, bool) {
+		calls++
+		return "", false
+	}
+	firstLine := `RUN echo "in C, the operator is <<"`
+	got, err := CollectHeredocLines(firstLine, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != firstLine {
+		t.Fatalf("got %q, want %q", got, firstLine)
+	}
+	if calls != 0 {
+		t.Fatalf("next called %d times, want 0", calls)
+	}
+}
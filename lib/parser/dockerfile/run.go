@@ -0,0 +1,179 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dockerfile
+
+import (
+	"fmt"
+
+	"github.com/uber/makisu/lib/parser/dockerfile/shellparse"
+	"github.com/uber/makisu/lib/platform"
+	"github.com/uber/makisu/lib/progress"
+	"github.com/uber/makisu/lib/shell"
+)
+
+// RunDirective represents the "RUN" dockerfile command.
+type RunDirective struct {
+	*baseDirective
+	Shell []string
+
+	// ShellScript is the parsed shell-form AST, set only when this
+	// directive was written in shell form (as opposed to the JSON-array
+	// exec form).
+	ShellScript *shellparse.Script
+
+	// Heredocs holds the heredoc bodies attached to this RUN's command
+	// line, e.g. the body of `RUN <<EOF\n...\nEOF`. Empty for an ordinary
+	// RUN with no heredoc.
+	Heredocs []*shellparse.Heredoc
+}
+
+// Variables:
+//   Replaced from ARGs and ENVs from within our stage.
+// Formats:
+//   RUN ["<executable>", "<param>"...]
+//   RUN <command>
+//   RUN <<EOF
+//   ...
+//   EOF
+func newRunDirective(base *baseDirective, state *parsingState) (Directive, error) {
+	if err := base.replaceVarsCurrStage(state); err != nil {
+		return nil, err
+	}
+
+	if argv, ok := parseJSONArray(base.Args); ok {
+		return &RunDirective{baseDirective: base, Shell: argv}, nil
+	}
+
+	cmdLine, heredocs, err := shellparse.SplitHeredocs(base.Args)
+	if err != nil {
+		return nil, base.err(err)
+	}
+
+	script, err := shellparse.Parse(cmdLine)
+	if err != nil {
+		return nil, base.err(err)
+	}
+
+	cmd := []string{"/bin/sh", "-c", shellparse.Reconstruct(script)}
+	if script.IsSimple() && len(heredocs) == 0 {
+		// A heredoc still needs /bin/sh to read the materialized script
+		// file even when the command line itself is a bare `<<EOF`.
+		cmd = script.Argv()
+	}
+	return &RunDirective{
+		baseDirective: base,
+		Shell:         cmd,
+		ShellScript:   script,
+		Heredocs:      heredocs,
+	}, nil
+}
+
+// Add this command to the build stage.
+func (d *RunDirective) update(state *parsingState) error {
+	return state.addToCurrStage(d)
+}
+
+// RunOptions configures the environment a RunDirective.Execute call runs
+// the step's command in.
+type RunOptions struct {
+	// RootDir is the container rootfs being built; a heredoc's script is
+	// written to a temp file under it before being invoked.
+	RootDir string
+	// WorkingDir and User are passed straight through to the shell
+	// package, same as every other directive that execs a command.
+	WorkingDir string
+	User       string
+	// ShellPath is the interpreter a heredoc body is run with; defaults
+	// to "/bin/sh" when empty. A SHELL directive earlier in the stage
+	// would set this to something else.
+	ShellPath string
+	// Writer, if non-nil, reports this RUN step's progress (Vertex
+	// lifecycle, per-line logs, byte-count status) instead of just
+	// streaming stdout/stderr through outStream/errStream. Heredoc RUNs
+	// don't yet have a progress-reporting execution path, so Writer only
+	// takes effect for the ordinary Shell argv form.
+	Writer progress.Writer
+	// Target and Host gate execution the same way `FROM --platform=`
+	// does: if Target doesn't match Host and EmulatorPath is empty, the
+	// RUN step is refused rather than silently run as the wrong
+	// architecture. A zero Target disables the guard entirely, since
+	// most builds aren't cross-platform. Heredoc RUNs don't yet go
+	// through this guard. The guard applies whether or not Writer is
+	// also set.
+	Target, Host platform.Spec
+	EmulatorPath string
+	// Backend selects how the plain (no Writer, no Target) execution
+	// path runs the step's user: the default BackendSetuid, or
+	// BackendRootless to map the requested uid/gid into a user namespace
+	// instead, selected by a builder option such as `--rootless`.
+	Backend shell.ExecBackend
+}
+
+// Execute runs the RUN step's resolved command. A heredoc body is passed
+// to shell.ExecHeredocScript, which writes it to a temp file under
+// RootDir and invokes it directly, rather than `-c`, since the body is
+// itself a multi-line script. An ordinary RUN execs the resolved Shell
+// argv via shell.ExecCommandWithProgress/ExecCommandPlatformWithProgress
+// when opts.Writer is set (the latter when opts.Target is also set, so
+// the cross-platform guard still applies with progress reporting on),
+// shell.ExecCommandPlatform when only opts.Target is set, or
+// shell.ExecCommandUser otherwise, which honors opts.Backend.
+func (d *RunDirective) Execute(opts RunOptions, outStream, errStream func(string, ...interface{})) error {
+	if len(d.Heredocs) > 1 {
+		// A RUN line with more than one `<<DELIM` has no single agreed
+		// shell semantics to execute it under - unlike COPY, where each
+		// heredoc cleanly materializes its own destination file, a second
+		// heredoc body on a RUN line would need to be fed to the command
+		// as a named file descriptor or redirection target the parser
+		// doesn't track. Refuse rather than silently running only the
+		// first body.
+		return fmt.Errorf("dockerfile: RUN with %d heredocs is not supported, only a single heredoc body can be executed", len(d.Heredocs))
+	}
+	if len(d.Heredocs) > 0 {
+		shellPath := opts.ShellPath
+		if shellPath == "" {
+			shellPath = "/bin/sh"
+		}
+		return shell.ExecHeredocScript(
+			outStream, errStream, opts.RootDir, opts.WorkingDir, opts.User,
+			shellPath, d.Heredocs[0].Body)
+	}
+	if opts.Writer != nil {
+		if opts.Target != (platform.Spec{}) {
+			return shell.ExecCommandPlatformWithProgress(
+				opts.Writer, opts.WorkingDir, opts.User,
+				opts.Target, opts.Host, opts.EmulatorPath, d.Shell[0], d.Shell[1:]...)
+		}
+		return shell.ExecCommandWithProgress(opts.Writer, opts.WorkingDir, opts.User, d.Shell[0], d.Shell[1:]...)
+	}
+	if opts.Target != (platform.Spec{}) {
+		return shell.ExecCommandPlatform(
+			outStream, errStream, opts.WorkingDir, opts.User,
+			opts.Target, opts.Host, opts.EmulatorPath, d.Shell[0], d.Shell[1:]...)
+	}
+	return shell.ExecCommandUser(opts.Backend, outStream, errStream, opts.WorkingDir, opts.User, d.Shell[0], d.Shell[1:]...)
+}
+
+// PlatformArgs is the integration point the ARG resolver must call before
+// replaceVarsCurrStage/splitArgs see a stage's Dockerfile text: it wraps
+// platform.BuildArgs so TARGETPLATFORM/TARGETOS/TARGETARCH/TARGETVARIANT/
+// BUILDPLATFORM are available to every directive in the stage, the same
+// way user-supplied --build-arg values are merged in. The ARG resolver
+// itself - and FromDirective's `--platform=$TARGETPLATFORM` handling -
+// aren't part of this package's snapshot; this is the seam they plug
+// into once they exist.
+func PlatformArgs(target, build platform.Spec) map[string]string {
+	return platform.BuildArgs(target, build)
+}
@@ -0,0 +1,210 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dockerfile
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/uber/makisu/lib/parser/dockerfile/shellparse"
+	"github.com/uber/makisu/lib/platform"
+	"github.com/uber/makisu/lib/progress"
+	"github.com/uber/makisu/lib/shell"
+)
+
+// fakeWriter records every Vertex/Log/Status report it receives, guarded
+// by a mutex since Log/Status are reported from concurrent goroutines.
+type fakeWriter struct {
+	mu       sync.Mutex
+	vertices []*progress.Vertex
+	logs     []*progress.VertexLog
+	statuses []*progress.VertexStatus
+}
+
+func (f *fakeWriter) Vertex(v *progress.Vertex) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.vertices = append(f.vertices, v)
+	return nil
+}
+
+func (f *fakeWriter) Log(l *progress.VertexLog) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.logs = append(f.logs, l)
+	return nil
+}
+
+func (f *fakeWriter) Status(s *progress.VertexStatus) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statuses = append(f.statuses, s)
+	return nil
+}
+
+func (f *fakeWriter) Close() error { return nil }
+
+func TestRunDirectiveExecutePlainCommand(t *testing.T) {
+	d := &RunDirective{Shell: []string{"/bin/sh", "-c", "echo hi"}}
+
+	var out strings.Builder
+	outStream := func(format string, args ...interface{}) { fmt.Fprintf(&out, format, args...) }
+	errStream := func(format string, args ...interface{}) {}
+
+	if err := d.Execute(RunOptions{}, outStream, errStream); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "hi\n" {
+		t.Fatalf("output = %q, want %q", got, "hi\n")
+	}
+}
+
+func TestRunDirectiveExecuteHeredoc(t *testing.T) {
+	d := &RunDirective{
+		Heredocs: []*shellparse.Heredoc{{Delimiter: "EOF", Body: "echo from heredoc"}},
+	}
+
+	var out strings.Builder
+	outStream := func(format string, args ...interface{}) { fmt.Fprintf(&out, format, args...) }
+	errStream := func(format string, args ...interface{}) {}
+
+	if err := d.Execute(RunOptions{RootDir: t.TempDir()}, outStream, errStream); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "from heredoc\n" {
+		t.Fatalf("output = %q, want %q", got, "from heredoc\n")
+	}
+}
+
+func TestRunDirectiveExecuteRefusesMultipleHeredocs(t *testing.T) {
+	d := &RunDirective{
+		Heredocs: []*shellparse.Heredoc{
+			{Delimiter: "A", Body: "body of A"},
+			{Delimiter: "B", Body: "body of B"},
+		},
+	}
+
+	err := d.Execute(RunOptions{RootDir: t.TempDir()}, func(string, ...interface{}) {}, func(string, ...interface{}) {})
+	if err == nil {
+		t.Fatal("expected an error refusing to execute a RUN with more than one heredoc")
+	}
+}
+
+func TestRunDirectiveExecuteWithWriterReportsProgress(t *testing.T) {
+	d := &RunDirective{Shell: []string{"/bin/sh", "-c", "echo hi"}}
+	w := &fakeWriter{}
+
+	if err := d.Execute(RunOptions{Writer: w}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.vertices) != 2 {
+		t.Fatalf("got %d vertex reports, want 2", len(w.vertices))
+	}
+	if len(w.statuses) == 0 {
+		t.Fatal("expected at least one VertexStatus")
+	}
+	for _, s := range w.statuses {
+		if s.Name == "" {
+			t.Fatalf("VertexStatus %+v has empty Name", s)
+		}
+	}
+}
+
+func TestRunDirectiveExecuteRefusesMismatchedPlatformWithoutEmulator(t *testing.T) {
+	d := &RunDirective{Shell: []string{"/bin/sh", "-c", "echo hi"}}
+	target := platform.Spec{OS: "linux", Architecture: "arm64"}
+	host := platform.Spec{OS: "linux", Architecture: "amd64"}
+
+	err := d.Execute(RunOptions{Target: target, Host: host}, func(string, ...interface{}) {}, func(string, ...interface{}) {})
+	if err == nil {
+		t.Fatal("expected an error refusing to run a mismatched platform without an emulator")
+	}
+}
+
+func TestRunDirectiveExecuteAllowsMatchingPlatform(t *testing.T) {
+	d := &RunDirective{Shell: []string{"/bin/sh", "-c", "echo hi"}}
+	host := platform.Host()
+
+	var out strings.Builder
+	outStream := func(format string, args ...interface{}) { fmt.Fprintf(&out, format, args...) }
+	err := d.Execute(RunOptions{Target: host, Host: host}, outStream, func(string, ...interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "hi\n" {
+		t.Fatalf("output = %q, want %q", got, "hi\n")
+	}
+}
+
+func TestRunDirectiveExecuteWriterAndTargetRefusesMismatchedPlatform(t *testing.T) {
+	d := &RunDirective{Shell: []string{"/bin/sh", "-c", "echo hi"}}
+	target := platform.Spec{OS: "linux", Architecture: "arm64"}
+	host := platform.Spec{OS: "linux", Architecture: "amd64"}
+	w := &fakeWriter{}
+
+	err := d.Execute(RunOptions{Writer: w, Target: target, Host: host}, func(string, ...interface{}) {}, func(string, ...interface{}) {})
+	if err == nil {
+		t.Fatal("expected an error refusing a mismatched platform even with a progress Writer set")
+	}
+	if len(w.vertices) != 0 {
+		t.Fatalf("got %d vertex reports, want 0 since the command must never start", len(w.vertices))
+	}
+}
+
+func TestRunDirectiveExecuteWriterAndTargetAllowsMatchingPlatform(t *testing.T) {
+	d := &RunDirective{Shell: []string{"/bin/sh", "-c", "echo hi"}}
+	host := platform.Host()
+	w := &fakeWriter{}
+
+	if err := d.Execute(RunOptions{Writer: w, Target: host, Host: host}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.vertices) != 2 {
+		t.Fatalf("got %d vertex reports, want 2", len(w.vertices))
+	}
+}
+
+func TestRunDirectiveExecuteUsesBackend(t *testing.T) {
+	d := &RunDirective{Shell: []string{"/bin/sh", "-c", "echo hi"}}
+
+	var out strings.Builder
+	outStream := func(format string, args ...interface{}) { fmt.Fprintf(&out, format, args...) }
+	errStream := func(format string, args ...interface{}) {}
+
+	if err := d.Execute(RunOptions{Backend: shell.BackendSetuid}, outStream, errStream); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "hi\n" {
+		t.Fatalf("output = %q, want %q", got, "hi\n")
+	}
+}
+
+func TestPlatformArgs(t *testing.T) {
+	target := platform.Spec{OS: "linux", Architecture: "arm64", Variant: "v8"}
+	build := platform.Spec{OS: "linux", Architecture: "amd64"}
+
+	args := PlatformArgs(target, build)
+	if args["TARGETPLATFORM"] != "linux/arm64/v8" {
+		t.Fatalf("TARGETPLATFORM = %q", args["TARGETPLATFORM"])
+	}
+	if args["TARGETARCH"] != "arm64" {
+		t.Fatalf("TARGETARCH = %q", args["TARGETARCH"])
+	}
+	if args["BUILDPLATFORM"] != "linux/amd64" {
+		t.Fatalf("BUILDPLATFORM = %q", args["BUILDPLATFORM"])
+	}
+}
@@ -0,0 +1,121 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shellparse
+
+// Script is the root of a parsed shell-form command: a sequence of
+// Statements joined by `;`, `&`, `&&`, `||`, or newlines.
+type Script struct {
+	Statements []*Statement
+}
+
+// Statement is a Pipeline together with the operator that followed it
+// ("", ";", "&", "&&", "||", or "\n"). The operator is kept so Reconstruct
+// can rebuild an equivalent command string for the `/bin/sh -c` fallback;
+// whitespace is normalized and a "\n" is rendered as " ;".
+type Statement struct {
+	Pipeline *Pipeline
+	Operator string
+}
+
+// Pipeline is one or more Blocks joined by `|`.
+type Pipeline struct {
+	Blocks []*Block
+}
+
+// Block is either a simple command (a list of Words, plus any Redirects)
+// or an opaque Subshell group; RUN/ENTRYPOINT treat a subshell, or a
+// command with redirects, as always requiring a shell to execute.
+type Block struct {
+	Words     []*Word
+	Redirects []*Redirect
+	Subshell  string // raw "(...)" text, set only when this Block is a subshell
+}
+
+// Redirect is a single `<`, `>`, or `>>` redirection attached to a Block,
+// e.g. the `> bar` in `echo foo > bar`. It is kept separate from Words so
+// IsSimple/Argv never mistake a redirect's target for a command argument.
+type Redirect struct {
+	// Op is the redirect operator's raw text: "<", ">", or ">>".
+	Op string
+	// Target is the redirect's destination/source word, e.g. "bar" in
+	// `> bar`. Nil if the operator had no following word (a parse error
+	// candidate callers may choose to surface).
+	Target *Word
+}
+
+// Word is a single shell word built from one or more WordParts, e.g.
+// `foo$BAR"baz"` is a single Word with a PartLiteral, PartParam, and
+// PartDoubleQuoted part.
+type Word struct {
+	Parts []WordPart
+	// Raw is the original source text of the word, used for the
+	// `/bin/sh -c` fallback string.
+	Raw string
+}
+
+// IsSimple reports whether the Script is a single command with no
+// pipeline/operator/subshell/expansion/substitution structure, i.e. safe
+// to exec directly rather than handing off to `/bin/sh -c`.
+func (s *Script) IsSimple() bool {
+	if len(s.Statements) != 1 {
+		return false
+	}
+	stmt := s.Statements[0]
+	if stmt.Operator != "" {
+		return false
+	}
+	if len(stmt.Pipeline.Blocks) != 1 {
+		return false
+	}
+	block := stmt.Pipeline.Blocks[0]
+	if block.Subshell != "" {
+		return false
+	}
+	if len(block.Redirects) > 0 {
+		return false
+	}
+	for _, w := range block.Words {
+		for _, p := range w.Parts {
+			switch p.Kind {
+			case PartParam, PartCommandSub:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Argv returns the literal argument vector of a simple Script, expanding
+// single- and double-quoted parts and omitting PartParam/PartCommandSub
+// parts entirely (callers should check IsSimple first if those must be
+// absent). It is used to build an exec.Cmd argv.
+func (s *Script) Argv() []string {
+	if len(s.Statements) == 0 {
+		return nil
+	}
+	block := s.Statements[0].Pipeline.Blocks[0]
+	argv := make([]string, 0, len(block.Words))
+	for _, w := range block.Words {
+		var lit string
+		for _, p := range w.Parts {
+			switch p.Kind {
+			case PartLiteral, PartSingleQuoted, PartDoubleQuoted:
+				lit += p.Text
+			}
+		}
+		argv = append(argv, lit)
+	}
+	return argv
+}
@@ -0,0 +1,80 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shellparse
+
+import "strings"
+
+// ExpandWord resolves the `$VAR`/`${VAR}`/`${VAR:-default}`/`${VAR:+alt}`
+// parts of a Word against env, leaving PartCommandSub parts as their raw
+// source text (callers that need real command substitution still fall back
+// to `/bin/sh -c`).
+func ExpandWord(w *Word, env map[string]string) string {
+	var sb strings.Builder
+	for _, p := range w.Parts {
+		switch p.Kind {
+		case PartLiteral, PartSingleQuoted, PartDoubleQuoted:
+			sb.WriteString(p.Text)
+		case PartCommandSub:
+			sb.WriteString(p.Text)
+		case PartParam:
+			sb.WriteString(expandParam(p.Text, env))
+		}
+	}
+	return sb.String()
+}
+
+// expandParam resolves the body of a ${...} or bare $VAR expansion.
+// Supported forms: VAR, VAR:-default, VAR:+alt.
+func expandParam(body string, env map[string]string) string {
+	name := body
+	op := ""
+	arg := ""
+
+	if idx := strings.Index(body, ":-"); idx >= 0 {
+		name, op, arg = body[:idx], ":-", body[idx+2:]
+	} else if idx := strings.Index(body, ":+"); idx >= 0 {
+		name, op, arg = body[:idx], ":+", body[idx+2:]
+	}
+
+	val, set := env[name]
+	switch op {
+	case ":-":
+		if !set || val == "" {
+			return arg
+		}
+		return val
+	case ":+":
+		if set && val != "" {
+			return arg
+		}
+		return ""
+	default:
+		return val
+	}
+}
+
+// ExpandScript expands every Word of a simple (single-command) Script
+// against env and returns the resulting argv.
+func ExpandScript(s *Script, env map[string]string) []string {
+	if len(s.Statements) == 0 {
+		return nil
+	}
+	block := s.Statements[0].Pipeline.Blocks[0]
+	argv := make([]string, 0, len(block.Words))
+	for _, w := range block.Words {
+		argv = append(argv, ExpandWord(w, env))
+	}
+	return argv
+}
@@ -0,0 +1,146 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shellparse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Heredoc is one `<<DELIM ... DELIM` body attached to a directive's
+// command line, e.g. the body of `RUN <<EOF\n...\nEOF`.
+type Heredoc struct {
+	// Delimiter is the tag that opens and closes the body, e.g. "EOF".
+	Delimiter string
+	// StripTabs is true for the `<<-EOF` form, which strips leading tabs
+	// from the body and the closing delimiter line.
+	StripTabs bool
+	// Quoted is true when the delimiter was written quoted (`<<'EOF'` or
+	// `<<"EOF"`). It only records how the delimiter was written: the
+	// RUN/COPY/ENTRYPOINT directive constructors replace ARGs/ENVs over
+	// the directive's whole text, heredoc bodies included, before
+	// SplitHeredocs runs, so a quoted delimiter does not stop expansion.
+	Quoted bool
+	// Body is the heredoc's content, not including the closing
+	// delimiter line.
+	Body string
+}
+
+// SplitHeredocs takes the raw, possibly multi-line text of a directive
+// (its command line followed by any heredoc bodies, as buffered by the
+// Dockerfile line reader while it waits for each delimiter to appear on
+// its own line), and separates the command line from the Heredoc bodies
+// carried by it. It supports multiple heredocs on one line, e.g.
+// `RUN <<A <<B\n...A's body...\nA\n...B's body...\nB`, returning their
+// Heredocs in the order the `<<` operators appeared.
+func SplitHeredocs(raw string) (cmdLine string, heredocs []*Heredoc, err error) {
+	firstNL := strings.IndexByte(raw, '\n')
+	if firstNL < 0 {
+		return raw, nil, nil
+	}
+	cmdLine = raw[:firstNL]
+	rest := raw[firstNL+1:]
+
+	tokens, err := Lex(cmdLine)
+	if err != nil {
+		return "", nil, fmt.Errorf("shellparse: lex heredoc command line: %s", err)
+	}
+
+	var specs []*Heredoc
+	for i, tok := range tokens {
+		if tok.Kind != TokenHeredocOp {
+			continue
+		}
+		delimTok, ok := nextWord(tokens, i+1)
+		if !ok {
+			return "", nil, fmt.Errorf("shellparse: %q has no heredoc delimiter after %q", cmdLine, tok.Raw)
+		}
+		delim, quoted := unquoteDelimiter(delimTok)
+		specs = append(specs, &Heredoc{
+			Delimiter: delim,
+			StripTabs: tok.Raw == "<<-",
+			Quoted:    quoted,
+		})
+	}
+	if len(specs) == 0 {
+		return cmdLine, nil, nil
+	}
+
+	for _, h := range specs {
+		body, remainder, err := readHeredocBody(rest, h.Delimiter, h.StripTabs)
+		if err != nil {
+			return "", nil, err
+		}
+		h.Body = body
+		rest = remainder
+	}
+
+	return cmdLine, specs, nil
+}
+
+func nextWord(tokens []Token, from int) (Token, bool) {
+	for i := from; i < len(tokens); i++ {
+		if tokens[i].Kind == TokenWhitespace {
+			continue
+		}
+		if tokens[i].Kind == TokenWord {
+			return tokens[i], true
+		}
+		return Token{}, false
+	}
+	return Token{}, false
+}
+
+// unquoteDelimiter strips a single layer of quoting from a heredoc
+// delimiter word, reporting whether it was quoted at all (either style
+// of quote disables body expansion).
+func unquoteDelimiter(tok Token) (delim string, quoted bool) {
+	var sb strings.Builder
+	for _, p := range tok.Parts {
+		switch p.Kind {
+		case PartSingleQuoted, PartDoubleQuoted:
+			quoted = true
+			sb.WriteString(p.Text)
+		default:
+			sb.WriteString(p.Text)
+		}
+	}
+	return sb.String(), quoted
+}
+
+// readHeredocBody consumes lines from rest until one equals delim (after
+// stripping leading tabs, if stripTabs), returning the body (not
+// including the delimiter line) and whatever text followed it.
+func readHeredocBody(rest, delim string, stripTabs bool) (body, remainder string, err error) {
+	lines := strings.Split(rest, "\n")
+	var bodyLines []string
+	for i, line := range lines {
+		check := line
+		if stripTabs {
+			check = strings.TrimLeft(line, "\t")
+		}
+		if check == delim {
+			remainder = strings.Join(lines[i+1:], "\n")
+			if stripTabs {
+				for j, bl := range bodyLines {
+					bodyLines[j] = strings.TrimLeft(bl, "\t")
+				}
+			}
+			return strings.Join(bodyLines, "\n"), remainder, nil
+		}
+		bodyLines = append(bodyLines, line)
+	}
+	return "", "", fmt.Errorf("shellparse: unterminated heredoc, missing closing %q", delim)
+}
@@ -0,0 +1,122 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shellparse
+
+import "testing"
+
+func TestSplitHeredocsSingle(t *testing.T) {
+	raw := "RUN <<EOF\necho one\necho two\nEOF"
+	cmdLine, heredocs, err := SplitHeredocs(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmdLine != "RUN <<EOF" {
+		t.Fatalf("cmdLine = %q, want %q", cmdLine, "RUN <<EOF")
+	}
+	if len(heredocs) != 1 {
+		t.Fatalf("got %d heredocs, want 1", len(heredocs))
+	}
+	if want := "echo one\necho two"; heredocs[0].Body != want {
+		t.Fatalf("body = %q, want %q", heredocs[0].Body, want)
+	}
+}
+
+func TestSplitHeredocsMultipleOnOneLine(t *testing.T) {
+	raw := "RUN <<A <<B\nbody of A\nA\nbody of B\nB"
+	cmdLine, heredocs, err := SplitHeredocs(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmdLine != "RUN <<A <<B" {
+		t.Fatalf("cmdLine = %q", cmdLine)
+	}
+	if len(heredocs) != 2 {
+		t.Fatalf("got %d heredocs, want 2", len(heredocs))
+	}
+	if heredocs[0].Delimiter != "A" || heredocs[0].Body != "body of A" {
+		t.Fatalf("heredocs[0] = %+v", heredocs[0])
+	}
+	if heredocs[1].Delimiter != "B" || heredocs[1].Body != "body of B" {
+		t.Fatalf("heredocs[1] = %+v", heredocs[1])
+	}
+}
+
+func TestSplitHeredocsStripTabs(t *testing.T) {
+	raw := "RUN <<-EOF\n\t\techo hi\n\tEOF"
+	_, heredocs, err := SplitHeredocs(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(heredocs) != 1 {
+		t.Fatalf("got %d heredocs, want 1", len(heredocs))
+	}
+	if !heredocs[0].StripTabs {
+		t.Fatal("expected StripTabs to be true for <<-")
+	}
+	if want := "echo hi"; heredocs[0].Body != want {
+		t.Fatalf("body = %q, want %q", heredocs[0].Body, want)
+	}
+}
+
+func TestSplitHeredocsQuotedDelimiterDisablesExpansion(t *testing.T) {
+	raw := "RUN <<'EOF'\necho $FOO\nEOF"
+	_, heredocs, err := SplitHeredocs(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(heredocs) != 1 {
+		t.Fatalf("got %d heredocs, want 1", len(heredocs))
+	}
+	if !heredocs[0].Quoted {
+		t.Fatal("expected Quoted to be true for <<'EOF'")
+	}
+	if heredocs[0].Delimiter != "EOF" {
+		t.Fatalf("delimiter = %q, want EOF", heredocs[0].Delimiter)
+	}
+}
+
+func TestSplitHeredocsBodyWithEmbeddedQuotes(t *testing.T) {
+	raw := "RUN <<EOF\necho \"it's a test\" 'and \"this\" too'\nEOF"
+	_, heredocs, err := SplitHeredocs(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(heredocs) != 1 {
+		t.Fatalf("got %d heredocs, want 1", len(heredocs))
+	}
+	want := `echo "it's a test" 'and "this" too'`
+	if heredocs[0].Body != want {
+		t.Fatalf("body = %q, want %q", heredocs[0].Body, want)
+	}
+}
+
+func TestSplitHeredocsNoNewlineIsNoOp(t *testing.T) {
+	cmdLine, heredocs, err := SplitHeredocs("RUN echo hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if heredocs != nil {
+		t.Fatalf("expected no heredocs, got %+v", heredocs)
+	}
+	if cmdLine != "RUN echo hi" {
+		t.Fatalf("cmdLine = %q", cmdLine)
+	}
+}
+
+func TestSplitHeredocsUnterminated(t *testing.T) {
+	if _, _, err := SplitHeredocs("RUN <<EOF\necho hi\n"); err == nil {
+		t.Fatal("expected error for unterminated heredoc")
+	}
+}
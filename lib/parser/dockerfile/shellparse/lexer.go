@@ -0,0 +1,391 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shellparse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Lexer turns a shell-form command string into a flat slice of Tokens.
+// It is intentionally forgiving: anything it cannot make sense of as an
+// operator is folded into the surrounding word, since the fallback for any
+// unrecognized construct is to hand the reconstructed string to `/bin/sh -c`.
+type Lexer struct {
+	input []rune
+	pos   int
+}
+
+// NewLexer creates a Lexer over input.
+func NewLexer(input string) *Lexer {
+	return &Lexer{input: []rune(input)}
+}
+
+// Lex tokenizes the entire input.
+func Lex(input string) ([]Token, error) {
+	l := NewLexer(input)
+	var tokens []Token
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, tok)
+		if tok.Kind == TokenEOF {
+			return tokens, nil
+		}
+	}
+}
+
+func (l *Lexer) peek() (rune, bool) {
+	if l.pos >= len(l.input) {
+		return 0, false
+	}
+	return l.input[l.pos], true
+}
+
+func (l *Lexer) peekAt(offset int) (rune, bool) {
+	if l.pos+offset >= len(l.input) {
+		return 0, false
+	}
+	return l.input[l.pos+offset], true
+}
+
+func (l *Lexer) next() (Token, error) {
+	start := l.pos
+	r, ok := l.peek()
+	if !ok {
+		return Token{Kind: TokenEOF, Pos: start}, nil
+	}
+
+	switch {
+	case r == ' ' || r == '\t':
+		for {
+			r, ok := l.peek()
+			if !ok || (r != ' ' && r != '\t') {
+				break
+			}
+			l.pos++
+		}
+		return Token{Kind: TokenWhitespace, Raw: string(l.input[start:l.pos]), Pos: start}, nil
+
+	case r == '\n':
+		l.pos++
+		return Token{Kind: TokenNewline, Raw: "\n", Pos: start}, nil
+
+	case r == ';':
+		l.pos++
+		return Token{Kind: TokenSemi, Raw: ";", Pos: start}, nil
+
+	case r == '|':
+		l.pos++
+		if n, ok := l.peek(); ok && n == '|' {
+			l.pos++
+			return Token{Kind: TokenOrIf, Raw: "||", Pos: start}, nil
+		}
+		return Token{Kind: TokenPipe, Raw: "|", Pos: start}, nil
+
+	case r == '&':
+		l.pos++
+		if n, ok := l.peek(); ok && n == '&' {
+			l.pos++
+			return Token{Kind: TokenAndIf, Raw: "&&", Pos: start}, nil
+		}
+		return Token{Kind: TokenAnd, Raw: "&", Pos: start}, nil
+
+	case r == '<':
+		if n, ok := l.peekAt(1); ok && n == '<' {
+			l.pos += 2
+			raw := "<<"
+			if n2, ok := l.peekAt(0); ok && n2 == '-' {
+				l.pos++
+				raw += "-"
+			}
+			return Token{Kind: TokenHeredocOp, Raw: raw, Pos: start}, nil
+		}
+		l.pos++
+		return Token{Kind: TokenRedirect, Raw: "<", Pos: start}, nil
+
+	case r == '>':
+		l.pos++
+		raw := ">"
+		if n, ok := l.peek(); ok && n == '>' {
+			l.pos++
+			raw += ">"
+		}
+		return Token{Kind: TokenRedirect, Raw: raw, Pos: start}, nil
+
+	case r == '(':
+		depth := 1
+		l.pos++
+		for depth > 0 {
+			r, ok := l.peek()
+			if !ok {
+				return Token{}, fmt.Errorf("shellparse: unterminated subshell starting at %d", start)
+			}
+			l.pos++
+			if r == '(' {
+				depth++
+			} else if r == ')' {
+				depth--
+			}
+		}
+		return Token{Kind: TokenSubshell, Raw: string(l.input[start:l.pos]), Pos: start}, nil
+	}
+
+	return l.lexWord(start)
+}
+
+// lexWord consumes a TokenWord: a run of literal text, quoted segments,
+// `$VAR`/`${...}` expansions, and command substitutions, stopping at
+// unescaped whitespace or an operator character.
+func (l *Lexer) lexWord(start int) (Token, error) {
+	var parts []WordPart
+	var lit strings.Builder
+
+	flushLit := func() {
+		if lit.Len() > 0 {
+			parts = append(parts, WordPart{Kind: PartLiteral, Text: lit.String()})
+			lit.Reset()
+		}
+	}
+
+	for {
+		r, ok := l.peek()
+		if !ok {
+			break
+		}
+		switch {
+		case isWordBreak(r):
+			goto done
+
+		case r == '\\':
+			// Backslash line continuation: swallow "\\\n" entirely.
+			if n, ok := l.peekAt(1); ok && n == '\n' {
+				l.pos += 2
+				continue
+			}
+			if ok2 := l.pos+1 < len(l.input); ok2 {
+				lit.WriteRune(l.input[l.pos+1])
+				l.pos += 2
+				continue
+			}
+			l.pos++
+
+		case r == '\'':
+			flushLit()
+			text, err := l.readSingleQuoted()
+			if err != nil {
+				return Token{}, err
+			}
+			parts = append(parts, WordPart{Kind: PartSingleQuoted, Text: text})
+
+		case r == '"':
+			flushLit()
+			inner, err := l.readDoubleQuoted()
+			if err != nil {
+				return Token{}, err
+			}
+			parts = append(parts, inner...)
+
+		case r == '`':
+			flushLit()
+			text, err := l.readBacktickSub()
+			if err != nil {
+				return Token{}, err
+			}
+			parts = append(parts, WordPart{Kind: PartCommandSub, Text: text})
+
+		case r == '$':
+			flushLit()
+			part, err := l.readDollar()
+			if err != nil {
+				return Token{}, err
+			}
+			parts = append(parts, part)
+
+		default:
+			lit.WriteRune(r)
+			l.pos++
+		}
+	}
+done:
+	flushLit()
+	return Token{Kind: TokenWord, Raw: string(l.input[start:l.pos]), Parts: parts, Pos: start}, nil
+}
+
+func isWordBreak(r rune) bool {
+	switch r {
+	case ' ', '\t', '\n', ';', '|', '&', '<', '>', '(':
+		return true
+	}
+	return false
+}
+
+func (l *Lexer) readSingleQuoted() (string, error) {
+	start := l.pos
+	l.pos++ // opening '
+	var sb strings.Builder
+	for {
+		r, ok := l.peek()
+		if !ok {
+			return "", fmt.Errorf("shellparse: unterminated single quote starting at %d", start)
+		}
+		l.pos++
+		if r == '\'' {
+			return sb.String(), nil
+		}
+		sb.WriteRune(r)
+	}
+}
+
+// readDoubleQuoted returns the WordParts found inside a double-quoted run:
+// literal text interleaved with `$VAR`/`${...}` expansions and command
+// substitutions, all still subject to expansion unlike single quotes.
+func (l *Lexer) readDoubleQuoted() ([]WordPart, error) {
+	start := l.pos
+	l.pos++ // opening "
+	var parts []WordPart
+	var lit strings.Builder
+
+	flushLit := func() {
+		if lit.Len() > 0 {
+			parts = append(parts, WordPart{Kind: PartDoubleQuoted, Text: lit.String()})
+			lit.Reset()
+		}
+	}
+
+	for {
+		r, ok := l.peek()
+		if !ok {
+			return nil, fmt.Errorf("shellparse: unterminated double quote starting at %d", start)
+		}
+		switch r {
+		case '"':
+			l.pos++
+			flushLit()
+			return parts, nil
+		case '\\':
+			if n, ok := l.peekAt(1); ok && (n == '"' || n == '\\' || n == '$' || n == '`') {
+				lit.WriteRune(n)
+				l.pos += 2
+				continue
+			}
+			lit.WriteRune(r)
+			l.pos++
+		case '$':
+			flushLit()
+			part, err := l.readDollar()
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, part)
+		case '`':
+			flushLit()
+			text, err := l.readBacktickSub()
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, WordPart{Kind: PartCommandSub, Text: text})
+		default:
+			lit.WriteRune(r)
+			l.pos++
+		}
+	}
+}
+
+func (l *Lexer) readBacktickSub() (string, error) {
+	start := l.pos
+	l.pos++ // opening `
+	for {
+		r, ok := l.peek()
+		if !ok {
+			return "", fmt.Errorf("shellparse: unterminated command substitution starting at %d", start)
+		}
+		l.pos++
+		if r == '`' {
+			return string(l.input[start:l.pos]), nil
+		}
+	}
+}
+
+// readDollar parses `$VAR`, `${VAR}`, `${VAR:-default}`, `${VAR:+alt}`, and
+// `$( ... )` starting at a '$'. Command substitutions are kept as opaque
+// PartCommandSub nodes; everything else becomes a PartParam.
+func (l *Lexer) readDollar() (WordPart, error) {
+	start := l.pos
+	l.pos++ // '$'
+
+	if r, ok := l.peek(); ok && r == '(' {
+		depth := 1
+		l.pos++
+		for depth > 0 {
+			r, ok := l.peek()
+			if !ok {
+				return WordPart{}, fmt.Errorf("shellparse: unterminated $(...) starting at %d", start)
+			}
+			l.pos++
+			if r == '(' {
+				depth++
+			} else if r == ')' {
+				depth--
+			}
+		}
+		return WordPart{Kind: PartCommandSub, Text: string(l.input[start:l.pos])}, nil
+	}
+
+	if r, ok := l.peek(); ok && r == '{' {
+		l.pos++
+		bodyStart := l.pos
+		for {
+			r, ok := l.peek()
+			if !ok {
+				return WordPart{}, fmt.Errorf("shellparse: unterminated ${...} starting at %d", start)
+			}
+			if r == '}' {
+				body := string(l.input[bodyStart:l.pos])
+				l.pos++
+				return WordPart{Kind: PartParam, Text: body}, nil
+			}
+			l.pos++
+		}
+	}
+
+	// Bare $VAR: a leading letter/underscore followed by alnum/underscore,
+	// matching POSIX shell variable name rules.
+	nameStart := l.pos
+	for {
+		r, ok := l.peek()
+		if !ok || !isVarNameRune(r, l.pos == nameStart) {
+			break
+		}
+		l.pos++
+	}
+	if l.pos == nameStart {
+		// Lone '$' with nothing that looks like a name: treat it literally.
+		return WordPart{Kind: PartLiteral, Text: "$"}, nil
+	}
+	return WordPart{Kind: PartParam, Text: string(l.input[nameStart:l.pos])}, nil
+}
+
+func isVarNameRune(r rune, first bool) bool {
+	if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+		return true
+	}
+	if !first && r >= '0' && r <= '9' {
+		return true
+	}
+	return false
+}
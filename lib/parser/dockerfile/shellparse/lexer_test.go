@@ -0,0 +1,175 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shellparse
+
+import "testing"
+
+func TestLexWordParts(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []WordPart
+	}{
+		{
+			name:  "nested single quote inside double quote is literal",
+			input: `"it's fine"`,
+			want: []WordPart{
+				{Kind: PartDoubleQuoted, Text: "it's fine"},
+			},
+		},
+		{
+			name:  "nested double quote inside single quote is literal",
+			input: `'say "hi"'`,
+			want: []WordPart{
+				{Kind: PartSingleQuoted, Text: `say "hi"`},
+			},
+		},
+		{
+			name:  "escaped dollar in double quotes is literal, not expanded",
+			input: `"price: \$5"`,
+			want: []WordPart{
+				{Kind: PartDoubleQuoted, Text: "price: $5"},
+			},
+		},
+		{
+			name:  "escaped dollar outside quotes is literal",
+			input: `\$HOME`,
+			want: []WordPart{
+				{Kind: PartLiteral, Text: "$HOME"},
+			},
+		},
+		{
+			name:  "bare var expansion",
+			input: `$FOO`,
+			want: []WordPart{
+				{Kind: PartParam, Text: "FOO"},
+			},
+		},
+		{
+			name:  "braced var with default",
+			input: `${FOO:-bar}`,
+			want: []WordPart{
+				{Kind: PartParam, Text: "FOO:-bar"},
+			},
+		},
+		{
+			name:  "braced var with alt",
+			input: `${FOO:+bar}`,
+			want: []WordPart{
+				{Kind: PartParam, Text: "FOO:+bar"},
+			},
+		},
+		{
+			name:  "var expansion glued to literal",
+			input: `foo$BAR baz`,
+			want: []WordPart{
+				{Kind: PartLiteral, Text: "foo"},
+				{Kind: PartParam, Text: "BAR"},
+			},
+		},
+		{
+			name:  "lone dollar with no name is literal",
+			input: `$ `,
+			want: []WordPart{
+				{Kind: PartLiteral, Text: "$"},
+			},
+		},
+		{
+			name:  "backtick command substitution kept opaque",
+			input: "`date`",
+			want: []WordPart{
+				{Kind: PartCommandSub, Text: "`date`"},
+			},
+		},
+		{
+			name:  "dollar-paren command substitution kept opaque",
+			input: "$(date)",
+			want: []WordPart{
+				{Kind: PartCommandSub, Text: "$(date)"},
+			},
+		},
+		{
+			name:  "backslash line continuation is swallowed",
+			input: "foo\\\nbar",
+			want: []WordPart{
+				{Kind: PartLiteral, Text: "foobar"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := Lex(tt.input)
+			if err != nil {
+				t.Fatalf("Lex(%q): %s", tt.input, err)
+			}
+			var word Token
+			found := false
+			for _, tok := range tokens {
+				if tok.Kind == TokenWord {
+					word = tok
+					found = true
+					break
+				}
+			}
+			if !found {
+				t.Fatalf("Lex(%q): no TokenWord produced", tt.input)
+			}
+			if len(word.Parts) != len(tt.want) {
+				t.Fatalf("Lex(%q) parts = %+v, want %+v", tt.input, word.Parts, tt.want)
+			}
+			for i, p := range word.Parts {
+				if p.Kind != tt.want[i].Kind || p.Text != tt.want[i].Text {
+					t.Fatalf("Lex(%q) part[%d] = %+v, want %+v", tt.input, i, p, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLexUnterminatedQuote(t *testing.T) {
+	if _, err := Lex(`echo "unterminated`); err == nil {
+		t.Fatal("expected error for unterminated double quote")
+	}
+	if _, err := Lex(`echo 'unterminated`); err == nil {
+		t.Fatal("expected error for unterminated single quote")
+	}
+}
+
+func TestLexOperators(t *testing.T) {
+	tokens, err := Lex("a && b || c & d ; e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []TokenKind
+	for _, tok := range tokens {
+		if tok.Kind == TokenWhitespace {
+			continue
+		}
+		kinds = append(kinds, tok.Kind)
+	}
+	want := []TokenKind{
+		TokenWord, TokenAndIf, TokenWord, TokenOrIf, TokenWord,
+		TokenAnd, TokenWord, TokenSemi, TokenWord, TokenEOF,
+	}
+	if len(kinds) != len(want) {
+		t.Fatalf("got %v, want %v", kinds, want)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("token[%d] = %v, want %v", i, kinds[i], want[i])
+		}
+	}
+}
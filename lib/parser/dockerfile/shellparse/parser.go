@@ -0,0 +1,176 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shellparse
+
+import "fmt"
+
+// Parse lexes and parses a shell-form command string into a Script.
+func Parse(input string) (*Script, error) {
+	tokens, err := Lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	return p.parseScript()
+}
+
+type parser struct {
+	tokens []Token
+	pos    int
+}
+
+func (p *parser) cur() Token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) advance() Token {
+	t := p.tokens[p.pos]
+	if p.pos < len(p.tokens)-1 {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) skipSpace() {
+	for p.cur().Kind == TokenWhitespace {
+		p.advance()
+	}
+}
+
+func (p *parser) parseScript() (*Script, error) {
+	script := &Script{}
+	p.skipSpace()
+	for p.cur().Kind != TokenEOF {
+		pipeline, err := p.parsePipeline()
+		if err != nil {
+			return nil, err
+		}
+		stmt := &Statement{Pipeline: pipeline}
+
+		p.skipSpace()
+		switch p.cur().Kind {
+		case TokenSemi, TokenAnd, TokenAndIf, TokenOrIf, TokenNewline:
+			stmt.Operator = p.advance().Raw
+		}
+		script.Statements = append(script.Statements, stmt)
+		p.skipSpace()
+	}
+	return script, nil
+}
+
+func (p *parser) parsePipeline() (*Pipeline, error) {
+	pipeline := &Pipeline{}
+	for {
+		block, err := p.parseBlock()
+		if err != nil {
+			return nil, err
+		}
+		pipeline.Blocks = append(pipeline.Blocks, block)
+
+		p.skipSpace()
+		if p.cur().Kind != TokenPipe {
+			return pipeline, nil
+		}
+		p.advance()
+		p.skipSpace()
+	}
+}
+
+func (p *parser) parseBlock() (*Block, error) {
+	p.skipSpace()
+	if p.cur().Kind == TokenSubshell {
+		tok := p.advance()
+		return &Block{Subshell: tok.Raw}, nil
+	}
+
+	block := &Block{}
+	for {
+		switch p.cur().Kind {
+		case TokenWord:
+			tok := p.advance()
+			block.Words = append(block.Words, &Word{Parts: tok.Parts, Raw: tok.Raw})
+		case TokenWhitespace:
+			p.advance()
+		case TokenRedirect, TokenHeredocOp:
+			// Redirects (and heredoc operators, which are structurally
+			// identical here - the body itself is pulled out upstream by
+			// shellparse.SplitHeredocs) are their own Redirect node, kept
+			// out of Words so IsSimple/Argv never mistake the target for a
+			// plain argument; Reconstruct still renders them verbatim.
+			opTok := p.advance()
+			p.skipSpace()
+			redirect := &Redirect{Op: opTok.Raw}
+			if p.cur().Kind == TokenWord {
+				tok := p.advance()
+				redirect.Target = &Word{Parts: tok.Parts, Raw: tok.Raw}
+			}
+			block.Redirects = append(block.Redirects, redirect)
+		default:
+			if len(block.Words) == 0 {
+				return nil, fmt.Errorf("shellparse: unexpected token %q at position %d", p.cur().Raw, p.cur().Pos)
+			}
+			return block, nil
+		}
+		switch p.cur().Kind {
+		case TokenEOF, TokenSemi, TokenAnd, TokenAndIf, TokenOrIf, TokenNewline, TokenPipe:
+			return block, nil
+		}
+	}
+}
+
+// Reconstruct rebuilds the original shell source of a Script by
+// concatenating each Statement's Pipeline and Operator. It is used to
+// produce the string handed to `/bin/sh -c` when a Script is not IsSimple.
+func Reconstruct(s *Script) string {
+	var out string
+	for i, stmt := range s.Statements {
+		if i > 0 {
+			out += " "
+		}
+		for j, block := range stmt.Pipeline.Blocks {
+			if j > 0 {
+				out += " | "
+			}
+			if block.Subshell != "" {
+				out += block.Subshell
+				continue
+			}
+			for k, w := range block.Words {
+				if k > 0 {
+					out += " "
+				}
+				out += w.Raw
+			}
+			for _, r := range block.Redirects {
+				out += " " + r.Op
+				if r.Target != nil {
+					out += " " + r.Target.Raw
+				}
+			}
+		}
+		switch stmt.Operator {
+		case "":
+			// Last statement: nothing to render.
+		case "\n":
+			// A bare newline has no inline rendering of its own; ";" is
+			// the operator that separates two statements on one line with
+			// identical sequencing semantics.
+			out += " ;"
+		default:
+			out += " " + stmt.Operator
+		}
+	}
+	return out
+}
@@ -0,0 +1,131 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shellparse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsSimple(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"ls -la /tmp", true},
+		{`echo "hello world"`, true},
+		{`echo 'a $b'`, true},
+		{"echo $FOO", false},
+		{"echo ${FOO:-bar}", false},
+		{"echo `date`", false},
+		{"echo $(date)", false},
+		{"echo a && echo b", false},
+		{"echo a | cat", false},
+		{"echo a; echo b", false},
+		{"echo a &", false},
+		{"(echo a)", false},
+		{"echo foo > bar", false},
+		{"echo foo >> bar", false},
+		{"echo foo < bar", false},
+	}
+	for _, tt := range tests {
+		script, err := Parse(tt.input)
+		if err != nil {
+			t.Fatalf("Parse(%q): %s", tt.input, err)
+		}
+		if got := script.IsSimple(); got != tt.want {
+			t.Errorf("Parse(%q).IsSimple() = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestArgv(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"ls -la /tmp", []string{"ls", "-la", "/tmp"}},
+		{`echo "hello world"`, []string{"echo", "hello world"}},
+		{`echo 'a $b' "c\"d"`, []string{"echo", "a $b", `c"d`}},
+	}
+	for _, tt := range tests {
+		script, err := Parse(tt.input)
+		if err != nil {
+			t.Fatalf("Parse(%q): %s", tt.input, err)
+		}
+		if got := script.Argv(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q).Argv() = %#v, want %#v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestRedirectExcludedFromArgvAndReconstructed(t *testing.T) {
+	script, err := Parse("echo foo > bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if script.IsSimple() {
+		t.Fatal("script with a redirect must not be IsSimple")
+	}
+
+	block := script.Statements[0].Pipeline.Blocks[0]
+	if len(block.Redirects) != 1 {
+		t.Fatalf("got %d redirects, want 1", len(block.Redirects))
+	}
+	if block.Redirects[0].Op != ">" || block.Redirects[0].Target.Raw != "bar" {
+		t.Fatalf("redirect = %+v, want Op '>' Target 'bar'", block.Redirects[0])
+	}
+
+	want := "echo foo > bar"
+	if got := Reconstruct(script); got != want {
+		t.Fatalf("Reconstruct = %q, want %q", got, want)
+	}
+}
+
+func TestReconstructPreservesOperators(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"echo a && echo b", "echo a && echo b"},
+		{"echo a || echo b", "echo a || echo b"},
+		{"echo a | cat", "echo a | cat"},
+		{"echo a ; echo b", "echo a ; echo b"},
+		{"echo a\necho b", "echo a ; echo b"},
+	}
+	for _, tt := range tests {
+		script, err := Parse(tt.input)
+		if err != nil {
+			t.Fatalf("Parse(%q): %s", tt.input, err)
+		}
+		if got := Reconstruct(script); got != tt.want {
+			t.Errorf("Reconstruct(Parse(%q)) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseNestedQuotesAndEscapedDollar(t *testing.T) {
+	script, err := Parse(`echo "it's \$5" 'say "hi"'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !script.IsSimple() {
+		t.Fatal("expected a simple command")
+	}
+	want := []string{"echo", "it's $5", `say "hi"`}
+	if got := script.Argv(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Argv() = %#v, want %#v", got, want)
+	}
+}
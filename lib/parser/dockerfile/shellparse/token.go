@@ -0,0 +1,95 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package shellparse implements a small lexer/parser for the POSIX-ish shell
+// grammar accepted by the shell form of RUN/CMD/ENTRYPOINT. It is not a full
+// shell implementation: it understands enough structure (words, quoting,
+// variable expansion, pipelines, and the `&`/`|`/`;` operators) to let callers
+// decide whether a command can be exec'd directly or must be handed off to
+// `/bin/sh -c`.
+package shellparse
+
+// TokenKind identifies the lexical class of a Token.
+type TokenKind int
+
+// Token kinds emitted by the Lexer.
+const (
+	// TokenWord is a contiguous run of non-whitespace, non-operator
+	// characters, possibly containing quoted or expanded sub-parts.
+	TokenWord TokenKind = iota
+	// TokenWhitespace is a run of space/tab characters separating words.
+	TokenWhitespace
+	// TokenPipe is the `|` pipeline operator.
+	TokenPipe
+	// TokenAndIf is the `&&` statement operator.
+	TokenAndIf
+	// TokenOrIf is the `||` statement operator.
+	TokenOrIf
+	// TokenAnd is the `&` background operator.
+	TokenAnd
+	// TokenSemi is the `;` statement separator.
+	TokenSemi
+	// TokenNewline is `\n`, which also terminates a statement.
+	TokenNewline
+	// TokenHeredocOp is the `<<` or `<<-` heredoc redirect operator.
+	TokenHeredocOp
+	// TokenRedirect is any other `<`/`>`/`>>` redirect operator.
+	TokenRedirect
+	// TokenSubshell is a parenthesized `( ... )` group.
+	TokenSubshell
+	// TokenEOF marks the end of input.
+	TokenEOF
+)
+
+// Token is a single lexical unit produced by the Lexer.
+type Token struct {
+	Kind TokenKind
+	// Raw is the verbatim source text of the token, used to reconstruct
+	// the original shell string for `/bin/sh -c` fallback.
+	Raw string
+	// Parts holds the decomposed sub-segments of a TokenWord: literal
+	// text, quoted runs, and `$VAR`/`${...}` expansions. Empty for all
+	// other token kinds.
+	Parts []WordPart
+	Pos   int
+}
+
+// WordPartKind distinguishes the pieces that make up a Word.
+type WordPartKind int
+
+// WordPart kinds.
+const (
+	// PartLiteral is unquoted, unexpanded text.
+	PartLiteral WordPartKind = iota
+	// PartSingleQuoted is text inside single quotes: no expansion.
+	PartSingleQuoted
+	// PartDoubleQuoted is text inside double quotes: `$VAR` expansion
+	// still applies, but word-splitting and globbing do not.
+	PartDoubleQuoted
+	// PartParam is a `$VAR` or `${VAR...}` parameter expansion.
+	PartParam
+	// PartCommandSub is a backtick or `$( ... )` command substitution,
+	// kept opaque: its literal source is preserved so the result can
+	// still be handed to `/bin/sh -c` verbatim.
+	PartCommandSub
+)
+
+// WordPart is one segment of a TokenWord.
+type WordPart struct {
+	Kind WordPartKind
+	// Text is the literal payload for PartLiteral/PartSingleQuoted/
+	// PartDoubleQuoted/PartCommandSub, or the raw body of a PartParam
+	// (e.g. `VAR`, `VAR:-default`, `VAR:+alt`).
+	Text string
+}
@@ -0,0 +1,103 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package platform parses and matches the `--platform` build flag,
+// wrapping github.com/containerd/platforms so makisu's CLI, builder, and
+// directive layer share one definition of "platform" with the rest of the
+// container ecosystem.
+package platform
+
+import (
+	"fmt"
+	"strings"
+
+	cdplatforms "github.com/containerd/platforms"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Spec identifies a target OS/architecture/variant, e.g. "linux/arm64/v8".
+type Spec struct {
+	OS           string
+	Architecture string
+	Variant      string
+}
+
+// Parse parses a "os/arch[/variant]" string, e.g. "linux/arm64" or
+// "linux/arm/v7", normalizing architecture aliases the same way Docker
+// and containerd do (e.g. "x86_64" -> "amd64").
+func Parse(s string) (Spec, error) {
+	p, err := cdplatforms.Parse(s)
+	if err != nil {
+		return Spec{}, fmt.Errorf("platform: parse %q: %s", s, err)
+	}
+	norm := cdplatforms.Normalize(p)
+	return Spec{OS: norm.OS, Architecture: norm.Architecture, Variant: norm.Variant}, nil
+}
+
+// ParseAll parses a comma-separated `--platform=linux/arm64,linux/amd64`
+// flag value into a Spec per entry.
+func ParseAll(s string) ([]Spec, error) {
+	var specs []Spec
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		p, err := Parse(part)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, p)
+	}
+	return specs, nil
+}
+
+// String renders the Spec back into "os/arch[/variant]" form.
+func (s Spec) String() string {
+	if s.Variant == "" {
+		return fmt.Sprintf("%s/%s", s.OS, s.Architecture)
+	}
+	return fmt.Sprintf("%s/%s/%s", s.OS, s.Architecture, s.Variant)
+}
+
+// Matches reports whether target can run on host without emulation.
+func (s Spec) Matches(host Spec) bool {
+	return cdplatforms.Only(toOCI(host)).Match(toOCI(s))
+}
+
+func toOCI(s Spec) specs.Platform {
+	return specs.Platform{OS: s.OS, Architecture: s.Architecture, Variant: s.Variant}
+}
+
+// Host returns the Spec of the machine makisu is running on.
+func Host() Spec {
+	p := cdplatforms.Normalize(cdplatforms.DefaultSpec())
+	return Spec{OS: p.OS, Architecture: p.Architecture, Variant: p.Variant}
+}
+
+// BuildArgs returns the automatic ARGs Docker/BuildKit expose to every
+// FROM stage: TARGETPLATFORM/TARGETOS/TARGETARCH/TARGETVARIANT for the
+// stage's own target, and BUILDPLATFORM for the host doing the building.
+// dockerfile.PlatformArgs wraps it for the directive layer; nothing in
+// makisu merges the result into the ARG map that replaceVarsCurrStage
+// reads.
+func BuildArgs(target, build Spec) map[string]string {
+	return map[string]string{
+		"TARGETPLATFORM": target.String(),
+		"TARGETOS":       target.OS,
+		"TARGETARCH":     target.Architecture,
+		"TARGETVARIANT":  target.Variant,
+		"BUILDPLATFORM":  build.String(),
+	}
+}
@@ -0,0 +1,70 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progress
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// PlainWriter renders progress as the plain line-oriented stream makisu
+// has always produced: vertex start/complete banners, then each log line
+// as-is. It is the default Writer, preserving existing output for callers
+// that don't care about structured progress.
+type PlainWriter struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewPlainWriter creates a PlainWriter that writes to out.
+func NewPlainWriter(out io.Writer) *PlainWriter {
+	return &PlainWriter{out: out}
+}
+
+// Vertex implements Writer.
+func (w *PlainWriter) Vertex(v *Vertex) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch {
+	case v.Completed != nil && v.Error != "":
+		_, err := fmt.Fprintf(w.out, "%s: failed: %s\n", v.Name, v.Error)
+		return err
+	case v.Completed != nil:
+		_, err := fmt.Fprintf(w.out, "%s: done\n", v.Name)
+		return err
+	default:
+		_, err := fmt.Fprintf(w.out, "%s\n", v.Name)
+		return err
+	}
+}
+
+// Log implements Writer.
+func (w *PlainWriter) Log(l *VertexLog) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.out.Write(l.Data)
+	return err
+}
+
+// Status implements Writer.
+func (w *PlainWriter) Status(s *VertexStatus) error {
+	return nil
+}
+
+// Close implements Writer.
+func (w *PlainWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,96 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package progress models a structured build progress stream, one Vertex
+// per executed step, in the same shape as the protocol BuildKit feeds to
+// the docker CLI: a vertex lifecycle (started/completed), per-line
+// logs tagged with stream and timestamp, and byte-count status updates.
+package progress
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Stream identifies which output stream a VertexLog line came from.
+type Stream int
+
+// Recognized Stream values.
+const (
+	StreamStdout Stream = 1
+	StreamStderr Stream = 2
+)
+
+// Vertex is one node in the build's progress graph: a single RUN step,
+// keyed by a stable digest derived from its command so re-runs of an
+// unchanged step report the same ID.
+type Vertex struct {
+	ID        string
+	Name      string
+	Started   *time.Time
+	Completed *time.Time
+	Error     string
+	ExitCode  int
+}
+
+// VertexLog is a single line of output from a Vertex, tagged with which
+// stream it came from and when it was produced.
+type VertexLog struct {
+	VertexID  string
+	Stream    Stream
+	Data      []byte
+	Timestamp time.Time
+}
+
+// VertexStatus reports incremental byte-count progress for a Vertex, e.g.
+// bytes read/written so far out of a known or unknown total.
+type VertexStatus struct {
+	VertexID string
+	Name     string
+	Current  int64
+	Total    int64 // 0 when unknown
+}
+
+// VertexID derives a stable digest-based vertex ID from a command's argv,
+// the way BuildKit derives vertex digests from the op it represents: the
+// same command always yields the same ID, so a Writer (or a
+// cache) can recognize a repeated step across builds.
+func VertexID(cmdName string, cmdArgs ...string) string {
+	h := sha256.New()
+	fmt.Fprint(h, cmdName)
+	for _, a := range cmdArgs {
+		fmt.Fprint(h, "\x00", a)
+	}
+	return fmt.Sprintf("sha256:%x", h.Sum(nil))
+}
+
+// Name renders a human-readable vertex name from a command by joining
+// cmdName and cmdArgs with spaces, e.g. `/bin/sh -c echo hello`.
+func Name(cmdName string, cmdArgs ...string) string {
+	return strings.TrimSpace(strings.Join(append([]string{cmdName}, cmdArgs...), " "))
+}
+
+// Writer is a pluggable sink for progress events. Implementations must be
+// safe to call from the goroutines that stream a command's stdout/stderr,
+// since VertexLog events arrive concurrently with the Vertex's own
+// lifecycle events.
+type Writer interface {
+	Vertex(v *Vertex) error
+	Log(l *VertexLog) error
+	Status(s *VertexStatus) error
+	// Close flushes and releases any resources held by the Writer.
+	Close() error
+}
@@ -0,0 +1,70 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progress
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// jsonEvent is the one-object-per-line wire format emitted by
+// RawJSONWriter, so CI systems can parse build progress without
+// depending on makisu's internal types.
+type jsonEvent struct {
+	Type   string        `json:"type"`
+	Vertex *Vertex       `json:"vertex,omitempty"`
+	Log    *VertexLog    `json:"log,omitempty"`
+	Status *VertexStatus `json:"status,omitempty"`
+}
+
+// RawJSONWriter emits one JSON object per progress event, newline
+// delimited, so build progress can be parsed by CI systems instead of
+// scraped from human-readable output.
+type RawJSONWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewRawJSONWriter creates a RawJSONWriter that writes to out.
+func NewRawJSONWriter(out io.Writer) *RawJSONWriter {
+	return &RawJSONWriter{enc: json.NewEncoder(out)}
+}
+
+// Vertex implements Writer.
+func (w *RawJSONWriter) Vertex(v *Vertex) error {
+	return w.write(jsonEvent{Type: "vertex", Vertex: v})
+}
+
+// Log implements Writer.
+func (w *RawJSONWriter) Log(l *VertexLog) error {
+	return w.write(jsonEvent{Type: "log", Log: l})
+}
+
+// Status implements Writer.
+func (w *RawJSONWriter) Status(s *VertexStatus) error {
+	return w.write(jsonEvent{Type: "status", Status: s})
+}
+
+// Close implements Writer.
+func (w *RawJSONWriter) Close() error {
+	return nil
+}
+
+func (w *RawJSONWriter) write(e jsonEvent) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.enc.Encode(e)
+}
@@ -0,0 +1,114 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progress
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/containerd/console"
+)
+
+// TTYWriter renders a live, redrawn-in-place tree of steps, one line per
+// Vertex, the way `docker build` renders BuildKit progress on an
+// interactive terminal. Log lines are not printed individually; only the
+// most recent one is shown, to keep the tree a fixed height.
+type TTYWriter struct {
+	mu       sync.Mutex
+	con      console.Console
+	order    []string
+	vertices map[string]*Vertex
+	lastLog  map[string]string
+	drawn    int
+}
+
+// NewTTYWriter creates a TTYWriter driving con, typically
+// console.ConsoleFromFile(os.Stdout).
+func NewTTYWriter(con console.Console) *TTYWriter {
+	return &TTYWriter{
+		con:      con,
+		vertices: make(map[string]*Vertex),
+		lastLog:  make(map[string]string),
+	}
+}
+
+// Vertex implements Writer.
+func (w *TTYWriter) Vertex(v *Vertex) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.vertices[v.ID]; !ok {
+		w.order = append(w.order, v.ID)
+	}
+	cp := *v
+	w.vertices[v.ID] = &cp
+	return w.redrawLocked()
+}
+
+// Log implements Writer.
+func (w *TTYWriter) Log(l *VertexLog) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.lastLog[l.VertexID] = strings.TrimRight(string(l.Data), "\r\n")
+	return w.redrawLocked()
+}
+
+// Status implements Writer.
+func (w *TTYWriter) Status(s *VertexStatus) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if s.Total > 0 {
+		w.lastLog[s.VertexID] = fmt.Sprintf("%s: %d/%d bytes", s.Name, s.Current, s.Total)
+	} else {
+		w.lastLog[s.VertexID] = fmt.Sprintf("%s: %d bytes", s.Name, s.Current)
+	}
+	return w.redrawLocked()
+}
+
+// Close implements Writer.
+func (w *TTYWriter) Close() error {
+	return nil
+}
+
+// redrawLocked erases the previously drawn tree and redraws it, moving
+// the cursor up w.drawn lines first. Callers must hold w.mu.
+func (w *TTYWriter) redrawLocked() error {
+	ids := w.order
+
+	if w.drawn > 0 {
+		fmt.Fprintf(w.con, "\x1b[%dA", w.drawn)
+	}
+	for _, id := range ids {
+		v := w.vertices[id]
+		status := "..."
+		if v.Completed != nil {
+			if v.Error != "" {
+				status = "error"
+			} else {
+				status = "done"
+			}
+		}
+		fmt.Fprintf(w.con, "\x1b[2K[%s] %s", status, v.Name)
+		if detail, ok := w.lastLog[id]; ok && detail != "" {
+			fmt.Fprintf(w.con, " | %s", detail)
+		}
+		fmt.Fprint(w.con, "\n")
+	}
+	w.drawn = len(ids)
+	return nil
+}
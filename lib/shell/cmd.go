@@ -22,6 +22,7 @@ import (
 	"strings"
 	"syscall"
 
+	"github.com/uber/makisu/lib/platform"
 	"github.com/uber/makisu/lib/utils"
 )
 
@@ -30,6 +31,101 @@ const ShellStreamBufferSize = 1 << 20
 
 // ExecCommand exec a cmd and args inside workingDir as user, returns error if cmd fails
 func ExecCommand(outStream, errStream func(string, ...interface{}), workingDir, user, cmdName string, cmdArgs ...string) error {
+	cmd, err := buildCmd(workingDir, user, cmdName, cmdArgs...)
+	if err != nil {
+		return err
+	}
+	return runAndStream(cmd, outStream, errStream)
+}
+
+// runAndStream wires cmd's stdout/stderr to outStream/errStream, starts
+// it, and waits for it to finish. Shared by ExecCommand and the rootless
+// backend in rootless.go, which only differ in how cmd.SysProcAttr is
+// built.
+func runAndStream(cmd *exec.Cmd, outStream, errStream func(string, ...interface{})) error {
+	outReader, outWriter := io.Pipe()
+	errReader, errWriter := io.Pipe()
+	cmd.Stdout, cmd.Stderr = outWriter, errWriter
+
+	done := make(chan struct{}, 2)
+	go func() {
+		defer func() { done <- struct{}{} }()
+		if err := readerToStream(outReader, outStream); err != nil {
+			outStream("Failed to stream stdout from command: %s\n", err)
+		}
+	}()
+
+	go func() {
+		defer func() { done <- struct{}{} }()
+		if err := readerToStream(errReader, errStream); err != nil {
+			errStream("Failed to stream stderr from command: %s\n", err)
+		}
+	}()
+
+	waitErr := func() error {
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("cmd start: %s", err)
+		}
+		return cmd.Wait()
+	}()
+
+	// cmd.Wait() only waits for the process and exec's own internal copy
+	// into outWriter/errWriter to finish; it never closes them, so
+	// without this, readerToStream's Read would block on EOF forever and
+	// leak both streaming goroutines for the life of the process.
+	outWriter.Close()
+	errWriter.Close()
+
+	// Wait for both streams to deliver their last output before returning.
+	<-done
+	<-done
+
+	if waitErr != nil {
+		if exitError, ok := waitErr.(*exec.ExitError); ok {
+			// Command exited with code other than 0.
+			ws := exitError.Sys().(syscall.WaitStatus)
+			exitCode := ws.ExitStatus()
+			errStream("Command exited with %d\n", exitCode)
+			return exitError
+		}
+		return fmt.Errorf("cmd wait: %s", waitErr)
+	}
+	return nil
+}
+
+// ExecCommandPlatform is ExecCommand, but first refuses to run if target
+// is a platform the host can't execute natively (e.g. building `FROM
+// --platform=linux/arm64` on an amd64 host) and no emulator binary is
+// registered for it, the same guard `docker buildx` applies before
+// handing a RUN step to qemu-user-static under binfmt_misc.
+//
+// When target doesn't match host, emulatorPath, if non-empty, is
+// prepended to cmdName/cmdArgs so the
+// emulator can exec the target-arch binary directly, mirroring how
+// binfmt_misc invokes e.g. /usr/bin/qemu-aarch64-static under the hood.
+func ExecCommandPlatform(
+	outStream, errStream func(string, ...interface{}),
+	workingDir, user string,
+	target, host platform.Spec,
+	emulatorPath string,
+	cmdName string, cmdArgs ...string,
+) error {
+	if !target.Matches(host) {
+		if emulatorPath == "" {
+			return fmt.Errorf(
+				"cmd exec: target platform %s does not match host platform %s, and no emulator was provided",
+				target, host)
+		}
+		cmdArgs = append([]string{cmdName}, cmdArgs...)
+		cmdName = emulatorPath
+	}
+	return ExecCommand(outStream, errStream, workingDir, user, cmdName, cmdArgs...)
+}
+
+// buildCmd constructs the exec.Cmd shared by ExecCommand and
+// ExecCommandWithProgress: working directory, process group, and the
+// user/HOME credential swap.
+func buildCmd(workingDir, user, cmdName string, cmdArgs ...string) (*exec.Cmd, error) {
 	cmd := exec.Command(cmdName, cmdArgs...)
 	if workingDir != "" {
 		cmd.Dir = workingDir
@@ -43,7 +139,7 @@ func ExecCommand(outStream, errStream func(string, ...interface{}), workingDir,
 		// Set the user to the one specified before
 		uid, gid, err := utils.ResolveChown(user)
 		if err != nil {
-			return fmt.Errorf("cmd user resolve: %s", err)
+			return nil, fmt.Errorf("cmd user resolve: %s", err)
 		}
 
 		uid32 := uint32(uid)
@@ -58,36 +154,7 @@ func ExecCommand(outStream, errStream func(string, ...interface{}), workingDir,
 	}
 
 	cmd.Env = currentEnv
-
-	outReader, outWriter := io.Pipe()
-	errReader, errWriter := io.Pipe()
-	cmd.Stdout, cmd.Stderr = outWriter, errWriter
-
-	go func() {
-		if err := readerToStream(outReader, outStream); err != nil {
-			outStream("Failed to stream stdout from command: %s\n", err)
-		}
-	}()
-
-	go func() {
-		if err := readerToStream(errReader, errStream); err != nil {
-			errStream("Failed to stream stderr from command: %s\n", err)
-		}
-	}()
-
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("cmd start: %s", err)
-	} else if err := cmd.Wait(); err != nil {
-		if exitError, ok := err.(*exec.ExitError); ok {
-			// Command exited with code other than 0.
-			ws := exitError.Sys().(syscall.WaitStatus)
-			exitCode := ws.ExitStatus()
-			errStream("Command exited with %d\n", exitCode)
-			return exitError
-		}
-		return fmt.Errorf("cmd wait: %s", err)
-	}
-	return nil
+	return cmd, nil
 }
 
 func readerToStream(reader io.Reader, stream func(string, ...interface{})) error {
@@ -0,0 +1,48 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shell
+
+import (
+	"fmt"
+	"os"
+)
+
+// ExecHeredocScript runs a RUN heredoc's body by writing it to a temp file
+// under rootDir (the container rootfs being built) and invoking
+// `shellPath <tmpfile>`, rather than `shellPath -c "<body>"`. This matters
+// for heredocs because the body is often itself a multi-line script with
+// its own quoting, which is fragile to pass through `-c`, and because a
+// `SHELL` directive may have set shellPath/args to something other than
+// "/bin/sh -c" that doesn't take a script on stdin-as-arg the same way.
+func ExecHeredocScript(outStream, errStream func(string, ...interface{}), rootDir, workingDir, user, shellPath, body string) error {
+	f, err := os.CreateTemp(rootDir, "makisu-heredoc-*.sh")
+	if err != nil {
+		return fmt.Errorf("heredoc: create temp script: %s", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(body); err != nil {
+		f.Close()
+		return fmt.Errorf("heredoc: write temp script: %s", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("heredoc: close temp script: %s", err)
+	}
+	if err := os.Chmod(f.Name(), 0755); err != nil {
+		return fmt.Errorf("heredoc: chmod temp script: %s", err)
+	}
+
+	return ExecCommand(outStream, errStream, workingDir, user, shellPath, f.Name())
+}
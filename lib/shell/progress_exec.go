@@ -0,0 +1,152 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shell
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/uber/makisu/lib/platform"
+	"github.com/uber/makisu/lib/progress"
+)
+
+// ExecCommandWithProgress is ExecCommand, but additionally reports a
+// structured progress stream to w: one Vertex for the whole command, with
+// Started/Completed timestamps and an exit code, one VertexLog per output
+// line tagged with stream and timestamp, and Status updates carrying the
+// running byte count read from stdout+stderr. The Vertex's ID is a
+// digest derived from cmdName/cmdArgs, so the same step reports the same
+// ID across builds.
+func ExecCommandWithProgress(w progress.Writer, workingDir, user, cmdName string, cmdArgs ...string) error {
+	cmd, err := buildCmd(workingDir, user, cmdName, cmdArgs...)
+	if err != nil {
+		return err
+	}
+
+	id := progress.VertexID(cmdName, cmdArgs...)
+	name := progress.Name(cmdName, cmdArgs...)
+
+	started := time.Now()
+	if err := w.Vertex(&progress.Vertex{ID: id, Name: name, Started: &started}); err != nil {
+		return fmt.Errorf("progress: report vertex start: %s", err)
+	}
+
+	outReader, outWriter := io.Pipe()
+	errReader, errWriter := io.Pipe()
+	cmd.Stdout, cmd.Stderr = outWriter, errWriter
+
+	var total int64
+	done := make(chan struct{}, 2)
+	go streamLines(outReader, id, name, progress.StreamStdout, w, &total, done)
+	go streamLines(errReader, id, name, progress.StreamStderr, w, &total, done)
+
+	runErr := func() error {
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("cmd start: %s", err)
+		}
+		return cmd.Wait()
+	}()
+
+	// cmd.Wait() only waits for the process and exec's own internal
+	// copy into outWriter/errWriter to finish; it never closes them, so
+	// without this, streamLines's Read would block on EOF forever.
+	outWriter.Close()
+	errWriter.Close()
+
+	<-done
+	<-done
+
+	completed := time.Now()
+	vertex := &progress.Vertex{ID: id, Name: name, Started: &started, Completed: &completed}
+
+	if runErr != nil {
+		if exitError, ok := runErr.(*exec.ExitError); ok {
+			ws := exitError.Sys().(syscall.WaitStatus)
+			vertex.ExitCode = ws.ExitStatus()
+			vertex.Error = runErr.Error()
+			_ = w.Vertex(vertex)
+			return exitError
+		}
+		vertex.Error = runErr.Error()
+		_ = w.Vertex(vertex)
+		return fmt.Errorf("cmd wait: %s", runErr)
+	}
+
+	return w.Vertex(vertex)
+}
+
+// ExecCommandPlatformWithProgress is ExecCommandWithProgress, but first
+// applies the same cross-platform guard as ExecCommandPlatform: it
+// refuses to run if target doesn't match host and no emulator is
+// available, instead of silently reporting progress for a command
+// executing under the wrong architecture. This is the path
+// RunDirective.Execute takes when both a progress Writer and a Target
+// are set in RunOptions, so the platform guard chunk0-3 added can't be
+// bypassed just by also turning progress reporting on.
+func ExecCommandPlatformWithProgress(
+	w progress.Writer,
+	workingDir, user string,
+	target, host platform.Spec,
+	emulatorPath string,
+	cmdName string, cmdArgs ...string,
+) error {
+	if !target.Matches(host) {
+		if emulatorPath == "" {
+			return fmt.Errorf(
+				"cmd exec: target platform %s does not match host platform %s, and no emulator was provided",
+				target, host)
+		}
+		cmdArgs = append([]string{cmdName}, cmdArgs...)
+		cmdName = emulatorPath
+	}
+	return ExecCommandWithProgress(w, workingDir, user, cmdName, cmdArgs...)
+}
+
+// streamLines reads lines from reader, forwarding each as a VertexLog and
+// reporting the running byte count as a VertexStatus. It signals done
+// when reader is exhausted so the caller can wait for both the stdout
+// and stderr goroutines before reporting vertex completion.
+func streamLines(reader io.Reader, vertexID, vertexName string, stream progress.Stream, w progress.Writer, total *int64, done chan<- struct{}) {
+	defer func() { done <- struct{}{} }()
+
+	br := bufio.NewReaderSize(reader, ShellStreamBufferSize)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			data := make([]byte, len(line))
+			copy(data, line)
+
+			current := atomic.AddInt64(total, int64(len(data)))
+			_ = w.Log(&progress.VertexLog{
+				VertexID:  vertexID,
+				Stream:    stream,
+				Data:      data,
+				Timestamp: time.Now(),
+			})
+			_ = w.Status(&progress.VertexStatus{VertexID: vertexID, Name: vertexName, Current: current})
+		}
+
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			return
+		}
+	}
+}
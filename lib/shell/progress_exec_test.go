@@ -0,0 +1,91 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shell
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/uber/makisu/lib/progress"
+)
+
+// fakeWriter records every event reported to it, guarded by a mutex since
+// Log/Status are called concurrently from the stdout/stderr goroutines.
+type fakeWriter struct {
+	mu       sync.Mutex
+	vertices []*progress.Vertex
+	logs     []*progress.VertexLog
+	statuses []*progress.VertexStatus
+}
+
+func (f *fakeWriter) Vertex(v *progress.Vertex) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.vertices = append(f.vertices, v)
+	return nil
+}
+
+func (f *fakeWriter) Log(l *progress.VertexLog) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.logs = append(f.logs, l)
+	return nil
+}
+
+func (f *fakeWriter) Status(s *progress.VertexStatus) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statuses = append(f.statuses, s)
+	return nil
+}
+
+func (f *fakeWriter) Close() error { return nil }
+
+func TestExecCommandWithProgressReportsVertexLifecycle(t *testing.T) {
+	w := &fakeWriter{}
+	if err := ExecCommandWithProgress(w, "", "", "/bin/sh", "-c", "echo hi"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(w.vertices) != 2 {
+		t.Fatalf("got %d vertex reports, want 2 (start, completion)", len(w.vertices))
+	}
+	if w.vertices[0].Started == nil || w.vertices[0].Completed != nil {
+		t.Fatalf("first vertex report = %+v, want Started set and Completed nil", w.vertices[0])
+	}
+	if w.vertices[1].Completed == nil {
+		t.Fatalf("second vertex report = %+v, want Completed set", w.vertices[1])
+	}
+
+	if len(w.logs) == 0 {
+		t.Fatal("expected at least one VertexLog")
+	}
+}
+
+func TestExecCommandWithProgressStatusIncludesName(t *testing.T) {
+	w := &fakeWriter{}
+	if err := ExecCommandWithProgress(w, "", "", "/bin/sh", "-c", "echo hi"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(w.statuses) == 0 {
+		t.Fatal("expected at least one VertexStatus")
+	}
+	for _, s := range w.statuses {
+		if s.Name == "" {
+			t.Fatalf("VertexStatus %+v has empty Name", s)
+		}
+	}
+}
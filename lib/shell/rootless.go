@@ -0,0 +1,167 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shell
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/uber/makisu/lib/utils"
+)
+
+// ExecBackend selects how ExecCommandUser changes the executing user for
+// a USER directive: the default "setuid" backend (syscall.Credential,
+// which requires the parent process to already be root), or "rootless",
+// which maps the target uid/gid into a fresh user namespace instead.
+type ExecBackend int
+
+// Supported ExecBackend values.
+const (
+	BackendSetuid ExecBackend = iota
+	BackendRootless
+)
+
+// ExecCommandUser runs cmdName/cmdArgs as user using backend. When
+// backend is BackendRootless but the calling process is already real
+// root, it transparently falls back to the setuid path, since user
+// namespaces only exist to grant an unprivileged process the ability to
+// change uid/gid at all.
+func ExecCommandUser(backend ExecBackend, outStream, errStream func(string, ...interface{}), workingDir, user, cmdName string, cmdArgs ...string) error {
+	if backend == BackendSetuid || os.Getuid() == 0 {
+		return ExecCommand(outStream, errStream, workingDir, user, cmdName, cmdArgs...)
+	}
+	return execCommandRootless(outStream, errStream, workingDir, user, cmdName, cmdArgs...)
+}
+
+// execCommandRootless runs cmdName/cmdArgs in a new user namespace with
+// the requested uid/gid mapped to the calling process's own uid/gid (see
+// buildIDMappings), following the approach
+// popularized by rootless BuildKit/podman: CLONE_NEWUSER plus a
+// uid_map/gid_map written before exec, with setgroups disabled (required
+// by the kernel before an unprivileged process may write a gid_map at
+// all). utils.ResolveChown still resolves the requested USER to the
+// uid/gid to present *inside* the namespace.
+func execCommandRootless(outStream, errStream func(string, ...interface{}), workingDir, user, cmdName string, cmdArgs ...string) error {
+	uid, gid, err := utils.ResolveChown(user)
+	if err != nil {
+		return fmt.Errorf("rootless cmd user resolve: %s", err)
+	}
+
+	cmd := exec.Command(cmdName, cmdArgs...)
+	if workingDir != "" {
+		cmd.Dir = workingDir
+	}
+	cmd.Env = os.Environ()
+
+	uidMappings, gidMappings := buildIDMappings(uid, gid)
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid:                    true,
+		Cloneflags:                 syscall.CLONE_NEWUSER,
+		UidMappings:                uidMappings,
+		GidMappings:                gidMappings,
+		GidMappingsEnableSetgroups: false,
+	}
+
+	return runAndStream(cmd, outStream, errStream)
+}
+
+// buildIDMappings maps the requested container uid/gid (resolved from the
+// USER directive by utils.ResolveChown) to the calling process's real
+// uid/gid, so the child process - which the kernel still sees as the
+// calling process's real id - reports itself as containerUID/containerGID
+// inside the new namespace. Without this, the namespace's own creator
+// always shows up as uid 0 in it regardless of what USER asked for.
+//
+// When `newuidmap`/`newgidmap` are on PATH and the invoking user has a
+// `/etc/subuid`/`/etc/subgid` range configured, a second mapping entry
+// covering that range is added (offset past containerUID/containerGID so
+// it can't collide with the primary entry); the Go runtime detects more
+// than one UidMappings/GidMappings entry and shells out to
+// newuidmap/newgidmap itself to install them, since only the setuid
+// helper (not the unprivileged process) is allowed to write a uid_map
+// with more than one line.
+func buildIDMappings(containerUID, containerGID int) ([]syscall.SysProcIDMap, []syscall.SysProcIDMap) {
+	hostUID, hostGID := os.Getuid(), os.Getgid()
+
+	uidMappings := []syscall.SysProcIDMap{{ContainerID: containerUID, HostID: hostUID, Size: 1}}
+	gidMappings := []syscall.SysProcIDMap{{ContainerID: containerGID, HostID: hostGID, Size: 1}}
+
+	if !newIDMapAvailable() {
+		return uidMappings, gidMappings
+	}
+
+	if start, size, err := subIDRange("/etc/subuid", currentUsername()); err == nil && size > 0 {
+		uidMappings = append(uidMappings, syscall.SysProcIDMap{ContainerID: containerUID + 1, HostID: start, Size: size})
+	}
+	if start, size, err := subIDRange("/etc/subgid", currentUsername()); err == nil && size > 0 {
+		gidMappings = append(gidMappings, syscall.SysProcIDMap{ContainerID: containerGID + 1, HostID: start, Size: size})
+	}
+
+	return uidMappings, gidMappings
+}
+
+// newIDMapAvailable reports whether both newuidmap and newgidmap are on
+// PATH, i.e. whether sub-uid/gid ranges beyond a single mapped id can be
+// installed at all.
+func newIDMapAvailable() bool {
+	if _, err := exec.LookPath("newuidmap"); err != nil {
+		return false
+	}
+	if _, err := exec.LookPath("newgidmap"); err != nil {
+		return false
+	}
+	return true
+}
+
+// subIDRange looks up user's first allotted range in an /etc/subuid or
+// /etc/subgid formatted file ("user:start:count" per line).
+func subIDRange(path, user string) (start, size int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), ":")
+		if len(fields) != 3 || fields[0] != user {
+			continue
+		}
+		start, err = strconv.Atoi(fields[1])
+		if err != nil {
+			return 0, 0, err
+		}
+		size, err = strconv.Atoi(fields[2])
+		if err != nil {
+			return 0, 0, err
+		}
+		return start, size, nil
+	}
+	return 0, 0, fmt.Errorf("rootless: no %s entry for %s", path, user)
+}
+
+func currentUsername() string {
+	if u := os.Getenv("USER"); u != "" {
+		return u
+	}
+	return strconv.Itoa(os.Getuid())
+}
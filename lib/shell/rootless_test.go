@@ -0,0 +1,104 @@
+//  Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shell
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// captureStream returns a callback suitable for ExecCommand's
+// outStream/errStream parameters that appends everything written to sb.
+func captureStream(sb *strings.Builder) func(string, ...interface{}) {
+	return func(format string, args ...interface{}) {
+		fmt.Fprintf(sb, format, args...)
+	}
+}
+
+// TestExecCommandUserAsRoot exercises the "USER 1000:1000" + "RUN id"
+// case via the setuid backend, which requires the test binary itself to
+// be running as root.
+func TestExecCommandUserAsRoot(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("requires running as root to exercise the setuid backend")
+	}
+
+	var out strings.Builder
+	var errOut strings.Builder
+	err := ExecCommandUser(BackendSetuid, captureStream(&out), captureStream(&errOut), "", "1000:1000", "id", "-u")
+	if err != nil {
+		t.Fatalf("ExecCommandUser: %s (stderr: %s)", err, errOut.String())
+	}
+	if got := strings.TrimSpace(out.String()); got != "1000" {
+		t.Fatalf("id -u = %q, want 1000", got)
+	}
+}
+
+// TestExecCommandUserRootlessFallback checks that BackendRootless falls
+// back to the setuid path - and still honors the requested USER - when
+// the calling process is already real root, since user namespaces exist
+// only to grant an *unprivileged* process the ability to change uid/gid.
+func TestExecCommandUserRootlessFallback(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("this test exercises the as-root fallback path specifically")
+	}
+
+	var out strings.Builder
+	var errOut strings.Builder
+	err := ExecCommandUser(BackendRootless, captureStream(&out), captureStream(&errOut), "", "1000:1000", "id", "-u")
+	if err != nil {
+		t.Fatalf("ExecCommandUser: %s (stderr: %s)", err, errOut.String())
+	}
+	if got := strings.TrimSpace(out.String()); got != "1000" {
+		t.Fatalf("id -u = %q, want 1000", got)
+	}
+}
+
+// TestExecCommandUserRootlessUnprivileged is the unprivileged-CI-user
+// half of the request: build a "USER 1000:1000" + "RUN id" step without
+// any host privilege, by mapping the calling process's own (non-root)
+// uid into a fresh user namespace as uid 1000. Skipped unless the test
+// binary is actually running unprivileged, since that's what the
+// rootless backend is for.
+func TestExecCommandUserRootlessUnprivileged(t *testing.T) {
+	if os.Getuid() == 0 {
+		t.Skip("requires running as a non-root CI user to exercise the rootless backend")
+	}
+
+	var out strings.Builder
+	var errOut strings.Builder
+	err := ExecCommandUser(BackendRootless, captureStream(&out), captureStream(&errOut), "", "1000:1000", "id", "-u")
+	if err != nil {
+		t.Fatalf("ExecCommandUser: %s (stderr: %s)", err, errOut.String())
+	}
+	if got := strings.TrimSpace(out.String()); got != "1000" {
+		t.Fatalf("id -u = %q, want 1000", got)
+	}
+}
+
+func TestBuildIDMappingsUsesRequestedIDs(t *testing.T) {
+	uidMappings, gidMappings := buildIDMappings(1000, 1000)
+	if uidMappings[0].ContainerID != 1000 {
+		t.Fatalf("uid mapping ContainerID = %d, want 1000", uidMappings[0].ContainerID)
+	}
+	if gidMappings[0].ContainerID != 1000 {
+		t.Fatalf("gid mapping ContainerID = %d, want 1000", gidMappings[0].ContainerID)
+	}
+	if uidMappings[0].HostID != os.Getuid() {
+		t.Fatalf("uid mapping HostID = %d, want %d", uidMappings[0].HostID, os.Getuid())
+	}
+}